@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// requireRole authenticates the caller and checks that their role is one of
+// roles. It writes the error response itself and reports whether the
+// handler should continue.
+func (cfg *apiConfig) requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (uuid.UUID, bool) {
+	user, err := cfg.authenticate(r)
+	if errors.Is(err, errSuspended) {
+		respondWithError(w, 403, "Account is suspended")
+		return uuid.Nil, false
+	}
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return uuid.Nil, false
+	}
+
+	for _, role := range roles {
+		if user.Role == role {
+			return user.ID, true
+		}
+	}
+
+	respondWithError(w, 403, "Forbidden")
+	return uuid.Nil, false
+}
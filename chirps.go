@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const (
+	chirpStatusDraft     = "draft"
+	chirpStatusScheduled = "scheduled"
+	chirpStatusPublished = "published"
+)
+
+const (
+	visibilityPublic    = "public"
+	visibilityFollowers = "followers"
+	visibilityUnlisted  = "unlisted"
+	visibilityPrivate   = "private"
+)
+
+// errChirpDeleted is returned together with the chirp when it has been
+// soft-deleted, so handlers can answer with a tombstone.
+var errChirpDeleted = errors.New("chirp has been deleted")
+
+type authorVal struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+type chirpVal struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+	Visibility string     `json:"visibility"`
+	Hidden     bool       `json:"hidden,omitempty"`
+	Media      []mediaVal `json:"media"`
+	Poll       *pollVal   `json:"poll,omitempty"`
+	Author     *authorVal `json:"author,omitempty"`
+
+	// Collapsed tells clients to blur the chirp behind its content
+	// warning until the reader opens it.
+	ContentWarning string `json:"content_warning,omitempty"`
+	Sensitive      bool   `json:"sensitive"`
+	Collapsed      bool   `json:"collapsed"`
+}
+
+// tombstoneVal is the 410 Gone body for a soft-deleted chirp.
+type tombstoneVal struct {
+	ID        uuid.UUID `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// resolveChirpStatus validates the status and publish_at a client asked
+// for. Leaving the status out publishes right away, unless publish_at is in
+// the future, in which case the chirp is scheduled.
+func resolveChirpStatus(status string, publishAt *time.Time, now time.Time) (string, sql.NullTime, error) {
+	switch status {
+	case "":
+		if publishAt != nil && publishAt.After(now) {
+			return chirpStatusScheduled, sql.NullTime{Time: publishAt.UTC(), Valid: true}, nil
+		}
+		return chirpStatusPublished, sql.NullTime{}, nil
+	case chirpStatusPublished, chirpStatusDraft:
+		if publishAt != nil {
+			return "", sql.NullTime{}, fmt.Errorf("publish_at can only be set on scheduled chirps")
+		}
+		return status, sql.NullTime{}, nil
+	case chirpStatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return "", sql.NullTime{}, fmt.Errorf("scheduled chirps need a publish_at in the future")
+		}
+		return status, sql.NullTime{Time: publishAt.UTC(), Valid: true}, nil
+	default:
+		return "", sql.NullTime{}, fmt.Errorf("unknown status %q", status)
+	}
+}
+
+func validateVisibility(visibility string) (string, error) {
+	switch visibility {
+	case "":
+		return visibilityPublic, nil
+	case visibilityPublic, visibilityFollowers, visibilityUnlisted, visibilityPrivate:
+		return visibility, nil
+	default:
+		return "", fmt.Errorf("unknown visibility %q", visibility)
+	}
+}
+
+// getVisibleChirp loads a chirp only if viewerID may read it. All read
+// paths that fetch a single chirp go through here; the rule itself lives in
+// the chirp_visible_to SQL function so list queries apply the same check.
+// A soft-deleted chirp comes back with errChirpDeleted.
+func (cfg *apiConfig) getVisibleChirp(ctx context.Context, viewerID, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.db.GetChirpForViewer(ctx, database.GetChirpForViewerParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if chirp.DeletedAt.Valid {
+		return chirp, errChirpDeleted
+	}
+
+	return chirp, nil
+}
+
+// wantsExpand reports whether the comma separated ?expand= query parameter
+// asks for the given relation, e.g. ?expand=author.
+func wantsExpand(r *http.Request, relation string) bool {
+	for _, v := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		if strings.TrimSpace(v) == relation {
+			return true
+		}
+	}
+	return false
+}
+
+// chirpVals converts chirps to their response shape as seen by viewerID.
+// Attachments, polls, the viewer's preferences, and author summaries when
+// expandAuthor is set, are loaded with one query each for the whole page.
+func (cfg *apiConfig) chirpVals(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp, expandAuthor bool) ([]chirpVal, error) {
+	retVals := make([]chirpVal, 0, len(chirps))
+	if len(chirps) == 0 {
+		return retVals, nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	authorIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+		authorIDs = append(authorIDs, chirp.UserID)
+	}
+
+	attachments, err := cfg.db.GetMediaForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaByChirp := make(map[uuid.UUID][]mediaVal)
+	for _, m := range attachments {
+		mediaByChirp[m.ChirpID.UUID] = append(mediaByChirp[m.ChirpID.UUID], newMediaVal(m))
+	}
+
+	polls, err := cfg.pollVals(ctx, viewerID, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	expandSensitive := false
+	if viewerID != uuid.Nil {
+		viewer, err := cfg.db.GetUserById(ctx, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		expandSensitive = viewer.ExpandSensitive
+	}
+
+	for _, chirp := range chirps {
+		chirpMedia := mediaByChirp[chirp.ID]
+		if chirpMedia == nil {
+			chirpMedia = []mediaVal{}
+		}
+
+		var publishAt *time.Time
+		if chirp.PublishAt.Valid {
+			publishAt = &chirp.PublishAt.Time
+		}
+
+		retVals = append(retVals, chirpVal{
+			ID:         chirp.ID,
+			CreatedAt:  chirp.CreatedAt,
+			UpdatedAt:  chirp.UpdatedAt,
+			Body:       chirp.Body,
+			UserID:     chirp.UserID,
+			Status:     chirp.Status,
+			PublishAt:  publishAt,
+			Visibility: chirp.Visibility,
+			Hidden:     chirp.HiddenAt.Valid,
+			Media:      chirpMedia,
+			Poll:       polls[chirp.ID],
+
+			ContentWarning: chirp.ContentWarning,
+			Sensitive:      chirp.Sensitive,
+			Collapsed:      isCollapsed(chirp, viewerID, expandSensitive),
+		})
+	}
+
+	if !expandAuthor {
+		return retVals, nil
+	}
+
+	summaries, err := cfg.db.GetUserSummaries(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make(map[uuid.UUID]*authorVal, len(summaries))
+	for _, s := range summaries {
+		authors[s.ID] = &authorVal{
+			ID:          s.ID,
+			Handle:      s.Handle,
+			DisplayName: s.DisplayName,
+			AvatarURL:   s.AvatarUrl,
+		}
+	}
+
+	for i := range retVals {
+		retVals[i].Author = authors[retVals[i].UserID]
+	}
+
+	return retVals, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestResolveChirpStatus(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+
+	cases := []struct {
+		status         string
+		publishAt      *time.Time
+		expectedStatus string
+		expectErr      bool
+	}{
+		{status: "", publishAt: nil, expectedStatus: chirpStatusPublished},
+		{status: "", publishAt: &future, expectedStatus: chirpStatusScheduled},
+		{status: "", publishAt: &past, expectedStatus: chirpStatusPublished},
+		{status: "draft", publishAt: nil, expectedStatus: chirpStatusDraft},
+		{status: "draft", publishAt: &future, expectErr: true},
+		{status: "scheduled", publishAt: &future, expectedStatus: chirpStatusScheduled},
+		{status: "scheduled", publishAt: &past, expectErr: true},
+		{status: "scheduled", publishAt: nil, expectErr: true},
+		{status: "archived", publishAt: nil, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			status, publishAt, err := resolveChirpStatus(c.status, c.publishAt, now)
+
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, have got status \"%v\"\n", status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v\n", err)
+			}
+
+			if status != c.expectedStatus {
+				t.Errorf("expected \"%v\", have got: \"%v\"\n", c.expectedStatus, status)
+			}
+
+			if publishAt.Valid != (status == chirpStatusScheduled) {
+				t.Errorf("publish_at must be set only for scheduled chirps, have got: %v\n", publishAt)
+			}
+		})
+	}
+}
+
+func TestValidateVisibility(t *testing.T) {
+	cases := []struct {
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{input: "", expected: visibilityPublic},
+		{input: "public", expected: visibilityPublic},
+		{input: "followers", expected: visibilityFollowers},
+		{input: "unlisted", expected: visibilityUnlisted},
+		{input: "private", expected: visibilityPrivate},
+		{input: "Public", expectErr: true},
+		{input: "friends", expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			visibility, err := validateVisibility(c.input)
+
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error=%v, have got: %v\n", c.expectErr, err)
+			}
+
+			if visibility != c.expected {
+				t.Errorf("expected \"%v\", have got: \"%v\"\n", c.expected, visibility)
+			}
+		})
+	}
+}
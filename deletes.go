@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/blobstore"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const (
+	purgeBatch     = 100
+	orphanMediaTTL = 24 * time.Hour
+)
+
+// HandleRestoreChirp undoes a delete, as long as the owner asks within
+// cfg.undoWindow of deleting the chirp.
+func (cfg *apiConfig) HandleRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the chirp ID is of type UUID")
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: sql.NullTime{Time: time.Now().UTC().Add(-cfg.undoWindow), Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.chirpVals(r.Context(), userID, []database.Chirp{chirp}, false)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVals[0])
+}
+
+func (cfg *apiConfig) purgeDeletedChirps(ctx context.Context) {
+	for {
+		cutoff := time.Now().UTC().Add(-cfg.retention)
+		rows, err := cfg.db.PurgeDeletedChirps(ctx, database.PurgeDeletedChirpsParams{
+			DeletedBefore: sql.NullTime{Time: cutoff, Valid: true},
+			BatchSize:     purgeBatch,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Could not purge deleted chirps: %v\n", err)
+			}
+			return
+		}
+
+		purged := map[uuid.UUID]bool{}
+		for _, row := range rows {
+			purged[row.ID] = true
+			if row.BlobKey.Valid {
+				cfg.deleteBlob(ctx, row.BlobKey.String)
+			}
+			if row.ThumbnailKey.Valid {
+				cfg.deleteBlob(ctx, row.ThumbnailKey.String)
+			}
+		}
+
+		if len(purged) > 0 {
+			log.Printf("Purged %d deleted chirps\n", len(purged))
+		}
+
+		if len(purged) < purgeBatch {
+			return
+		}
+	}
+}
+
+func (cfg *apiConfig) purgeOrphanMedia(ctx context.Context) {
+	rows, err := cfg.db.DeleteOrphanMedia(ctx, time.Now().UTC().Add(-orphanMediaTTL))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Could not purge unattached media: %v\n", err)
+		}
+		return
+	}
+
+	for _, row := range rows {
+		cfg.deleteBlob(ctx, row.BlobKey)
+		cfg.deleteBlob(ctx, row.ThumbnailKey)
+	}
+
+	if len(rows) > 0 {
+		log.Printf("Purged %d unattached uploads\n", len(rows))
+	}
+}
+
+// deleteBlob removes a stored file. Its database row is gone or was never
+// created, so a failure only leaves an unreferenced file behind.
+func (cfg *apiConfig) deleteBlob(ctx context.Context, key string) {
+	err := cfg.blobs.Delete(ctx, key)
+	if err != nil && !errors.Is(err, blobstore.ErrNotFound) {
+		log.Printf("Could not delete blob %v: %v\n", key, err)
+	}
+}
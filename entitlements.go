@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/audit"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/entitlements"
+)
+
+// userEntitlements is the one place that decides what user may do. The
+// plan follows the Chirpy Red subscription; grants come on top.
+func (cfg *apiConfig) userEntitlements(ctx context.Context, user database.User) (entitlements.Set, error) {
+	grants, err := cfg.db.GetActiveGrantCapabilities(ctx, user.ID)
+	if err != nil {
+		return entitlements.Set{}, err
+	}
+
+	capabilities := make([]entitlements.Capability, 0, len(grants))
+	for _, c := range grants {
+		capabilities = append(capabilities, entitlements.Capability(c))
+	}
+
+	plan := entitlements.Free
+	if user.IsChirpyRed {
+		plan = entitlements.Red
+	}
+
+	return entitlements.For(plan, capabilities), nil
+}
+
+type entitlementsVal struct {
+	Plan          string   `json:"plan"`
+	Capabilities  []string `json:"capabilities"`
+	ChirpLength   int      `json:"chirp_length"`
+	MediaPerChirp int      `json:"media_per_chirp"`
+}
+
+func newEntitlementsVal(s entitlements.Set) entitlementsVal {
+	capabilities := []string{}
+	for _, c := range s.List() {
+		capabilities = append(capabilities, string(c))
+	}
+
+	return entitlementsVal{
+		Plan:          string(s.Plan),
+		Capabilities:  capabilities,
+		ChirpLength:   s.ChirpLength(),
+		MediaPerChirp: s.MediaPerChirp(),
+	}
+}
+
+type entitlementGrantVal struct {
+	Capability string     `json:"capability"`
+	CreatedAt  time.Time  `json:"created_at"`
+	GrantedBy  *uuid.UUID `json:"granted_by"`
+	Reason     string     `json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func newEntitlementGrantVal(g database.EntitlementGrant) entitlementGrantVal {
+	var expiresAt *time.Time
+	if g.ExpiresAt.Valid {
+		expiresAt = &g.ExpiresAt.Time
+	}
+
+	return entitlementGrantVal{
+		Capability: g.Capability,
+		CreatedAt:  g.CreatedAt,
+		GrantedBy:  nullUUIDPtr(g.GrantedBy),
+		Reason:     g.Reason,
+		ExpiresAt:  expiresAt,
+	}
+}
+
+// grantTarget authenticates an admin and loads the user in the {userID}
+// path value.
+func (cfg *apiConfig) grantTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.User, bool) {
+	adminID, ok := cfg.requireRole(w, r, roleAdmin)
+	if !ok {
+		return uuid.Nil, database.User{}, false
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the user ID is of type UUID")
+		return uuid.Nil, database.User{}, false
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return uuid.Nil, database.User{}, false
+	}
+
+	return adminID, user, true
+}
+
+// HandleGetUserEntitlements shows an admin what a user may do and which
+// of it was granted individually.
+func (cfg *apiConfig) HandleGetUserEntitlements(w http.ResponseWriter, r *http.Request) {
+	type returnVal struct {
+		Entitlements entitlementsVal       `json:"entitlements"`
+		Grants       []entitlementGrantVal `json:"grants"`
+	}
+
+	_, user, ok := cfg.grantTarget(w, r)
+	if !ok {
+		return
+	}
+
+	ents, err := cfg.userEntitlements(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	grants, err := cfg.db.ListEntitlementGrants(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVal := returnVal{
+		Entitlements: newEntitlementsVal(ents),
+		Grants:       make([]entitlementGrantVal, 0, len(grants)),
+	}
+	for _, g := range grants {
+		retVal.Grants = append(retVal.Grants, newEntitlementGrantVal(g))
+	}
+
+	respondWithJSON(w, 200, retVal)
+}
+
+func (cfg *apiConfig) HandleGrantEntitlement(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason    string     `json:"reason"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	adminID, user, ok := cfg.grantTarget(w, r)
+	if !ok {
+		return
+	}
+
+	capability := entitlements.Capability(r.PathValue("capability"))
+	if !entitlements.IsCapability(capability) {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		respondWithError(w, 400, "A reason is required")
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			respondWithError(w, 400, "expires_at must be in the future")
+			return
+		}
+		expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+	}
+
+	grant, err := cfg.db.UpsertEntitlementGrant(r.Context(), database.UpsertEntitlementGrantParams{
+		UserID:     user.ID,
+		Capability: string(capability),
+		GrantedBy:  uuid.NullUUID{UUID: adminID, Valid: true},
+		Reason:     params.Reason,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	cfg.recordAudit(r, audit.Event{
+		Kind:     audit.EntitlementGranted,
+		ActorID:  adminID,
+		TargetID: user.ID,
+		Metadata: map[string]string{"capability": string(capability), "reason": params.Reason},
+	})
+
+	respondWithJSON(w, 200, newEntitlementGrantVal(grant))
+}
+
+func (cfg *apiConfig) HandleRevokeEntitlement(w http.ResponseWriter, r *http.Request) {
+	adminID, user, ok := cfg.grantTarget(w, r)
+	if !ok {
+		return
+	}
+
+	capability := r.PathValue("capability")
+	deleted, err := cfg.db.DeleteEntitlementGrant(r.Context(), database.DeleteEntitlementGrantParams{
+		UserID:     user.ID,
+		Capability: capability,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	cfg.recordAudit(r, audit.Event{
+		Kind:     audit.EntitlementRevoked,
+		ActorID:  adminID,
+		TargetID: user.ID,
+		Metadata: map[string]string{"capability": capability},
+	})
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/paysis/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) HandleFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	blocked, err := cfg.db.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{
+		UserID:  userID,
+		OtherID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if blocked {
+		respondWithError(w, 403, "Forbidden")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	created, err := qtx.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	follower, err := qtx.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	// Following someone again is a no-op and must not notify them twice.
+	// Follows by shadow-banned users are kept but nobody is told.
+	if created == 1 && !follower.ShadowBanned {
+		err := enqueueUserEvent(r.Context(), qtx, targetID, userEventFollowerAdded, followerEventData{FollowerID: userID})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+
+		if err := notifyFollow(r.Context(), qtx, targetID, userID); err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) HandleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
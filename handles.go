@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,20}$`)
+
+// reservedHandles can never be claimed because they collide with routes or
+// could be used to impersonate staff.
+var reservedHandles = []string{
+	"about",
+	"admin",
+	"administrator",
+	"api",
+	"app",
+	"chirpy",
+	"help",
+	"login",
+	"logout",
+	"me",
+	"moderator",
+	"null",
+	"root",
+	"settings",
+	"staff",
+	"support",
+	"system",
+	"undefined",
+}
+
+func validateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return fmt.Errorf("handle must be 3-20 letters, digits or underscores")
+	}
+
+	lower := strings.ToLower(handle)
+	for _, reserved := range reservedHandles {
+		if lower == reserved {
+			return fmt.Errorf("handle %q is reserved", handle)
+		}
+	}
+
+	return nil
+}
+
+// generateHandle gives new users a placeholder handle they can change later.
+func generateHandle() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return "user_" + hex.EncodeToString(buf), nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidateHandle(t *testing.T) {
+	cases := []struct {
+		input   string
+		isValid bool
+	}{
+		{input: "chirper_01", isValid: true},
+		{input: "ab", isValid: false},
+		{input: "this_handle_is_far_too_long", isValid: false},
+		{input: "has space", isValid: false},
+		{input: "émile", isValid: false},
+		{input: "Admin", isValid: false},
+		{input: "ME", isValid: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			err := validateHandle(c.input)
+
+			if (err == nil) != c.isValid {
+				t.Errorf("expected valid=%v for \"%v\", have got: %v\n", c.isValid, c.input, err)
+			}
+		})
+	}
+}
+
+func TestGenerateHandle(t *testing.T) {
+	handle, err := generateHandle()
+	if err != nil {
+		t.Fatalf("generateHandle returned err: %v\n", err)
+	}
+
+	if err := validateHandle(handle); err != nil {
+		t.Fatalf("generated handle %v is not valid: %v\n", handle, err)
+	}
+}
@@ -0,0 +1,172 @@
+// Package audit records security-relevant events in the append-only,
+// hash-chained audit_events table.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+type Kind string
+
+const (
+	UserCreated     Kind = "user.created"
+	LoginSucceeded  Kind = "login.succeeded"
+	LoginFailed     Kind = "login.failed"
+	EmailChanged    Kind = "user.email_changed"
+	PasswordChanged Kind = "user.password_changed"
+	TokenRevoked    Kind = "token.revoked"
+	RedUpgraded     Kind = "user.red_upgraded"
+	AdminReset      Kind = "admin.reset"
+
+	SubscriptionChanged Kind = "subscription.changed"
+	SubscriptionExpired Kind = "subscription.expired"
+	EntitlementGranted  Kind = "entitlement.granted"
+	EntitlementRevoked  Kind = "entitlement.revoked"
+)
+
+// Kinds lists every kind, for validating query filters.
+var Kinds = []Kind{
+	UserCreated,
+	LoginSucceeded,
+	LoginFailed,
+	EmailChanged,
+	PasswordChanged,
+	TokenRevoked,
+	RedUpgraded,
+	AdminReset,
+	SubscriptionChanged,
+	SubscriptionExpired,
+	EntitlementGranted,
+	EntitlementRevoked,
+}
+
+// Event is one thing that happened. ActorID is uuid.Nil for anonymous
+// callers and the system itself, TargetID is uuid.Nil when there is no
+// affected user.
+type Event struct {
+	Kind     Kind
+	ActorID  uuid.UUID
+	TargetID uuid.UUID
+	IP       string
+	Metadata map[string]string
+}
+
+type Log struct {
+	db      *sql.DB
+	queries *database.Queries
+	now     func() time.Time
+}
+
+func New(db *sql.DB) *Log {
+	return &Log{
+		db:      db,
+		queries: database.New(db),
+		now:     time.Now,
+	}
+}
+
+// Record appends e to the chain. Appends are serialized with an advisory
+// lock, so the row it chains onto is always the latest one.
+func (l *Log) Record(ctx context.Context, e Event) error {
+	if e.Metadata == nil {
+		e.Metadata = map[string]string{}
+	}
+
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := l.queries.WithTx(tx)
+
+	if err := qtx.LockAuditLog(ctx); err != nil {
+		return err
+	}
+
+	last, err := qtx.GetLastAuditEvent(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		last = database.AuditEvent{Hash: genesisHash}
+	} else if err != nil {
+		return err
+	}
+
+	// Postgres keeps microseconds, so truncate before hashing for the
+	// stored row to hash the same later.
+	event := database.AuditEvent{
+		ID:        last.ID + 1,
+		CreatedAt: l.now().UTC().Truncate(time.Microsecond),
+		Kind:      string(e.Kind),
+		ActorID:   uuid.NullUUID{UUID: e.ActorID, Valid: e.ActorID != uuid.Nil},
+		TargetID:  uuid.NullUUID{UUID: e.TargetID, Valid: e.TargetID != uuid.Nil},
+		Ip:        e.IP,
+		Metadata:  metadata,
+		PrevHash:  last.Hash,
+	}
+	event.Hash = Hash(event)
+
+	err = qtx.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt,
+		Kind:      event.Kind,
+		ActorID:   event.ActorID,
+		TargetID:  event.TargetID,
+		Ip:        event.Ip,
+		Metadata:  event.Metadata,
+		PrevHash:  event.PrevHash,
+		Hash:      event.Hash,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const verifyBatch = 1000
+
+// VerifyResult reports how much of the chain was checked and, when
+// BrokenAt is non-zero, the ID of the first row that does not fit.
+type VerifyResult struct {
+	Checked  int64
+	BrokenAt int64
+}
+
+// Verify walks the whole chain in ID order.
+func (l *Log) Verify(ctx context.Context) (VerifyResult, error) {
+	res := VerifyResult{}
+	prev := database.AuditEvent{Hash: genesisHash}
+
+	for {
+		events, err := l.queries.GetAuditEventsAfter(ctx, database.GetAuditEventsAfterParams{
+			ID:    prev.ID,
+			Limit: verifyBatch,
+		})
+		if err != nil {
+			return res, err
+		}
+		if len(events) == 0 {
+			return res, nil
+		}
+
+		checked, brokenAt := Check(prev, events)
+		res.Checked += checked
+		if brokenAt != 0 {
+			res.BrokenAt = brokenAt
+			return res, nil
+		}
+
+		prev = events[len(events)-1]
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+// genesisHash is the prev_hash of the first row.
+var genesisHash = make([]byte, sha256.Size)
+
+// Hash is the SHA-256 of the row's previous hash followed by its fields.
+// Fields are joined with NUL bytes, which Postgres does not allow inside
+// text, so no two rows encode the same way.
+func Hash(e database.AuditEvent) []byte {
+	fields := []string{
+		strconv.FormatInt(e.ID, 10),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Kind,
+		nullUUIDString(e.ActorID),
+		nullUUIDString(e.TargetID),
+		e.Ip,
+		string(e.Metadata),
+	}
+
+	h := sha256.New()
+	h.Write(e.PrevHash)
+	h.Write([]byte(strings.Join(fields, "\x00")))
+	return h.Sum(nil)
+}
+
+// Check verifies that events continue the chain after prev, which is the
+// zero AuditEvent with genesisHash for the start. It returns how many rows
+// passed and the ID of the first row that did not, or zero.
+func Check(prev database.AuditEvent, events []database.AuditEvent) (int64, int64) {
+	var checked int64
+	for _, e := range events {
+		if e.ID != prev.ID+1 || !bytes.Equal(e.PrevHash, prev.Hash) || !bytes.Equal(e.Hash, Hash(e)) {
+			return checked, e.ID
+		}
+		checked++
+		prev = e
+	}
+	return checked, 0
+}
+
+func nullUUIDString(id uuid.NullUUID) string {
+	if !id.Valid {
+		return ""
+	}
+	return id.UUID.String()
+}
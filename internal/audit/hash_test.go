@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+func testChain(n int) []database.AuditEvent {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	prevHash := genesisHash
+
+	events := make([]database.AuditEvent, 0, n)
+	for i := 0; i < n; i++ {
+		e := database.AuditEvent{
+			ID:        int64(i + 1),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			Kind:      string(LoginSucceeded),
+			ActorID:   uuid.NullUUID{UUID: uuid.New(), Valid: true},
+			Ip:        "203.0.113.7",
+			Metadata:  json.RawMessage(`{}`),
+			PrevHash:  prevHash,
+		}
+		e.Hash = Hash(e)
+		prevHash = e.Hash
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		tamper           func([]database.AuditEvent) []database.AuditEvent
+		expectedChecked  int64
+		expectedBrokenAt int64
+	}{
+		{
+			tamper:          func(events []database.AuditEvent) []database.AuditEvent { return events },
+			expectedChecked: 4,
+		},
+		{
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[1].Metadata = json.RawMessage(`{"note":"edited"}`)
+				return events
+			},
+			expectedChecked:  1,
+			expectedBrokenAt: 2,
+		},
+		{
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[1].Kind = string(LoginFailed)
+				events[1].Hash = Hash(events[1])
+				return events
+			},
+			expectedChecked:  2,
+			expectedBrokenAt: 3,
+		},
+		{
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				return append(events[:2], events[3:]...)
+			},
+			expectedChecked:  2,
+			expectedBrokenAt: 4,
+		},
+		{
+			tamper: func(events []database.AuditEvent) []database.AuditEvent {
+				events[0].ActorID = uuid.NullUUID{}
+				return events
+			},
+			expectedChecked:  0,
+			expectedBrokenAt: 1,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			events := c.tamper(testChain(4))
+
+			checked, brokenAt := Check(database.AuditEvent{Hash: genesisHash}, events)
+			if checked != c.expectedChecked || brokenAt != c.expectedBrokenAt {
+				t.Errorf("expected checked=%v brokenAt=%v, have got: checked=%v brokenAt=%v\n",
+					c.expectedChecked, c.expectedBrokenAt, checked, brokenAt)
+			}
+		})
+	}
+}
+
+func TestCheckContinuesAcrossBatches(t *testing.T) {
+	events := testChain(6)
+
+	checked, brokenAt := Check(events[2], events[3:])
+	if checked != 3 || brokenAt != 0 {
+		t.Errorf("expected checked=3 brokenAt=0, have got: checked=%v brokenAt=%v\n", checked, brokenAt)
+	}
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
@@ -23,6 +24,26 @@ func CheckPasswordHash(password, hash string) error {
 }
 
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresInArgs ...time.Duration) (string, error) {
+	return MakeSessionJWT(userID, "", tokenSecret, expiresInArgs...)
+}
+
+// Claims are what a validated JWT says about its holder.
+type Claims struct {
+	UserID uuid.UUID
+	// SessionID identifies the refresh token the JWT was issued with. It is
+	// empty for JWTs that are not tied to one.
+	SessionID string
+	ExpiresAt time.Time
+}
+
+type sessionClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+}
+
+// MakeSessionJWT works like MakeJWT and ties the token to a session, see
+// SessionID.
+func MakeSessionJWT(userID uuid.UUID, sessionID, tokenSecret string, expiresInArgs ...time.Duration) (string, error) {
 	var expiresIn time.Duration
 	if len(expiresInArgs) == 0 {
 		expiresIn = 1 * time.Hour
@@ -30,39 +51,57 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresInArgs ...time.Duratio
 		expiresIn = expiresInArgs[0]
 	}
 
-	claims := jwt.RegisteredClaims{
-		Issuer:    "Chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
+	claims := sessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "Chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		SessionID: sessionID,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(tokenSecret))
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
-
+	claims, err := ParseJWT(tokenString, tokenSecret)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
-	claims := token.Claims
-	sid, err := claims.GetSubject()
+	return claims.UserID, nil
+}
+
+// ParseJWT validates tokenString like ValidateJWT and returns its claims.
+func ParseJWT(tokenString, tokenSecret string) (Claims, error) {
+	claims := sessionClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithExpirationRequired())
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return Claims{}, err
 	}
 
-	uid, err := uuid.Parse(sid)
+	uid, err := uuid.Parse(claims.Subject)
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return Claims{}, err
 	}
 
-	return uid, nil
+	return Claims{
+		UserID:    uid,
+		SessionID: claims.SessionID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// SessionID derives the session of a refresh token. JWTs can be read by
+// anyone holding them, so they carry this instead of the refresh token.
+func SessionID(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:16])
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -93,6 +93,34 @@ func TestJWTWrongKey(t *testing.T) {
 	}
 }
 
+func TestSessionJWT(t *testing.T) {
+	tokenSecret := "TOPSECRETKEY"
+	subject := uuid.New()
+	sessionID := SessionID("refresh-token")
+
+	jwtStr, err := MakeSessionJWT(subject, sessionID, tokenSecret, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeSessionJWT returned err: %v\n", err)
+	}
+
+	claims, err := ParseJWT(jwtStr, tokenSecret)
+	if err != nil {
+		t.Fatalf("ParseJWT returned err: %v\n", err)
+	}
+
+	if claims.UserID != subject || claims.SessionID != sessionID {
+		t.Errorf("expected: %v %v, have got: %v %v\n", subject, sessionID, claims.UserID, claims.SessionID)
+	}
+
+	if until := time.Until(claims.ExpiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("expected the token to expire within a minute, have got: %v\n", claims.ExpiresAt)
+	}
+
+	if SessionID("other-token") == sessionID {
+		t.Errorf("expected different refresh tokens to have different sessions\n")
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	expected := "SD45F1E564S5F4E"
 	h := http.Header{}
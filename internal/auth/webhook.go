@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries webhook signatures in the form
+// "t=<unix seconds>,v1=<hex hmac>". Senders include one v1 entry per
+// active secret while they rotate keys.
+const SignatureHeader = "X-Polka-Signature"
+
+var (
+	ErrNoSignature        = errors.New("no signature header found")
+	ErrSignatureExpired   = errors.New("signature timestamp is outside the tolerance")
+	ErrSignatureMismatch  = errors.New("no signature matches")
+	errMalformedSignature = errors.New("signature header value is invalid")
+)
+
+// SignWebhook returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHeaderValue builds the header value for body signed with
+// every one of secrets.
+func SignatureHeaderValue(secrets []string, timestamp time.Time, body []byte) string {
+	parts := []string{fmt.Sprintf("t=%d", timestamp.Unix())}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+SignWebhook(secret, timestamp, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// VerifyWebhookSignature checks header against body. It passes if any v1
+// signature matches any of secrets and the timestamp is within tolerance
+// of now in either direction, which bounds how long a captured request
+// can be replayed.
+func VerifyWebhookSignature(header string, body []byte, secrets []string, now time.Time, tolerance time.Duration) error {
+	if header == "" {
+		return ErrNoSignature
+	}
+
+	var timestamp int64
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return errMalformedSignature
+		}
+
+		switch key {
+		case "t":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errMalformedSignature
+			}
+			timestamp = t
+		case "v1":
+			sig, err := hex.DecodeString(value)
+			if err != nil {
+				return errMalformedSignature
+			}
+			signatures = append(signatures, sig)
+		}
+	}
+
+	if timestamp == 0 || len(signatures) == 0 {
+		return errMalformedSignature
+	}
+
+	signedAt := time.Unix(timestamp, 0)
+	if now.Sub(signedAt) > tolerance || signedAt.Sub(now) > tolerance {
+		return ErrSignatureExpired
+	}
+
+	for _, secret := range secrets {
+		expected, _ := hex.DecodeString(SignWebhook(secret, signedAt, body))
+		for _, sig := range signatures {
+			if hmac.Equal(expected, sig) {
+				return nil
+			}
+		}
+	}
+
+	return ErrSignatureMismatch
+}
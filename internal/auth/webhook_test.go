@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	secrets := []string{"new-secret", "old-secret"}
+
+	cases := []struct {
+		header      string
+		body        []byte
+		expectedErr error
+	}{
+		{header: SignatureHeaderValue([]string{"new-secret"}, now, body), body: body},
+		{header: SignatureHeaderValue([]string{"old-secret"}, now.Add(-time.Minute), body), body: body},
+		{header: SignatureHeaderValue([]string{"other", "old-secret"}, now, body), body: body},
+		{header: SignatureHeaderValue([]string{"other"}, now, body), body: body, expectedErr: ErrSignatureMismatch},
+		{header: SignatureHeaderValue([]string{"new-secret"}, now, body), body: []byte(`{}`), expectedErr: ErrSignatureMismatch},
+		{header: SignatureHeaderValue([]string{"new-secret"}, now.Add(-10*time.Minute), body), body: body, expectedErr: ErrSignatureExpired},
+		{header: SignatureHeaderValue([]string{"new-secret"}, now.Add(10*time.Minute), body), body: body, expectedErr: ErrSignatureExpired},
+		{header: "", body: body, expectedErr: ErrNoSignature},
+		{header: "t=abc,v1=00", body: body, expectedErr: errMalformedSignature},
+		{header: fmt.Sprintf("t=%d", now.Unix()), body: body, expectedErr: errMalformedSignature},
+		{header: fmt.Sprintf("t=%d,v1=zz", now.Unix()), body: body, expectedErr: errMalformedSignature},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			err := VerifyWebhookSignature(c.header, c.body, secrets, now, 5*time.Minute)
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected: %v, have got: %v\n", c.expectedErr, err)
+			}
+		})
+	}
+}
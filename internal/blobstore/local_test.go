@@ -0,0 +1,53 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore returned err: %v\n", err)
+	}
+
+	if err := store.Put(ctx, "media/abc", strings.NewReader("hello"), "text/plain"); err != nil {
+		t.Fatalf("Put returned err: %v\n", err)
+	}
+
+	rc, err := store.Get(ctx, "media/abc")
+	if err != nil {
+		t.Fatalf("Get returned err: %v\n", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+
+	if string(data) != "hello" {
+		t.Fatalf("expected: hello, got %v\n", string(data))
+	}
+
+	if err := store.Delete(ctx, "media/abc"); err != nil {
+		t.Fatalf("Delete returned err: %v\n", err)
+	}
+
+	if _, err := store.Get(ctx, "media/abc"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete must have returned ErrNotFound, instead got: %v", err)
+	}
+}
+
+func TestLocalStoreRejectsTraversal(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore returned err: %v\n", err)
+	}
+
+	for _, key := range []string{"../escape", "/abs", "a//b", "a/./b", ""} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), ""); err == nil {
+			t.Errorf("Put(%q) must have failed\n", key)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package chirptext prepares user-written text for storage and measures it
+// the way readers see it.
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLWeight is what every link counts towards the length limit, however
+// long the URL itself is.
+const URLWeight = 23
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{3,20})\b`)
+
+// MaxHashtagLength is the longest tag Hashtags finds.
+const MaxHashtagLength = 50
+
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\w#&/])#(\d*[a-zA-Z_]\w{0,49})\b`)
+
+const (
+	zeroWidthJoiner    = '\u200d'
+	zeroWidthNonJoiner = '\u200c'
+)
+
+// Normalize converts s to NFC, removes control characters except newlines
+// and tabs, removes invisible formatting characters such as zero-width
+// spaces and bidi overrides, and trims surrounding whitespace.
+//
+// Joiners (ZWJ and ZWNJ) are kept between two visible characters because
+// emoji sequences and several scripts depend on them. Emoji tag characters
+// used by subdivision flags are kept as well.
+func Normalize(s string) string {
+	runes := []rune(norm.NFC.String(s))
+
+	kept := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch {
+		case r == '\n' || r == '\t':
+			kept = append(kept, r)
+		case r == '\r':
+			// Dropped so that CRLF and LF count the same.
+		case r == zeroWidthJoiner || r == zeroWidthNonJoiner:
+			if len(kept) > 0 && isVisible(kept[len(kept)-1]) && i+1 < len(runes) && isVisible(runes[i+1]) {
+				kept = append(kept, r)
+			}
+		case isEmojiTag(r):
+			kept = append(kept, r)
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+		default:
+			kept = append(kept, r)
+		}
+	}
+
+	return strings.TrimSpace(string(kept))
+}
+
+// Length counts user-perceived characters (grapheme clusters), so an emoji
+// made of several code points counts once. Each URL counts as URLWeight.
+func Length(s string) int {
+	length, start := 0, 0
+	for _, loc := range urlPattern.FindAllStringIndex(s, -1) {
+		length += uniseg.GraphemeClusterCount(s[start:loc[0]]) + URLWeight
+		start = loc[1]
+	}
+	return length + uniseg.GraphemeClusterCount(s[start:])
+}
+
+// Mentions returns the handles mentioned with "@handle" in s, lowercased
+// and without duplicates, in the order they first appear.
+func Mentions(s string) []string {
+	return uniqueMatches(mentionPattern, s)
+}
+
+// Hashtags returns the tags used with "#tag" in s the same way. A tag of
+// only digits, such as "#1", is not a hashtag.
+func Hashtags(s string) []string {
+	return uniqueMatches(hashtagPattern, s)
+}
+
+func uniqueMatches(pattern *regexp.Regexp, s string) []string {
+	var matches []string
+	seen := map[string]bool{}
+	for _, m := range pattern.FindAllStringSubmatch(s, -1) {
+		match := strings.ToLower(m[1])
+		if !seen[match] {
+			seen[match] = true
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+func isVisible(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsControl(r) && !unicode.Is(unicode.Cf, r)
+}
+
+func isEmojiTag(r rune) bool {
+	return r >= '\U000E0020' && r <= '\U000E007F'
+}
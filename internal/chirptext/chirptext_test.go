@@ -0,0 +1,129 @@
+package chirptext
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		input          string
+		expectedOutput string
+	}{
+		{
+			input:          "  hello world \n",
+			expectedOutput: "hello world",
+		},
+		{
+			// "e" followed by a combining acute accent becomes a single "é".
+			input:          "cafe\u0301",
+			expectedOutput: "caf\u00e9",
+		},
+		{
+			input:          "hi\u200bthere\u00ad\u202e!",
+			expectedOutput: "hithere!",
+		},
+		{
+			input:          "line one\r\nline two\x07",
+			expectedOutput: "line one\nline two",
+		},
+		{
+			// A family emoji keeps its joiners, a stray one is removed.
+			input:          "\u200d\U0001F468\u200d\U0001F469\u200d\U0001F467 \u200d",
+			expectedOutput: "\U0001F468\u200d\U0001F469\u200d\U0001F467",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			output := Normalize(c.input)
+
+			if output != c.expectedOutput {
+				t.Errorf("expected %q, have got: %q\n", c.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		input          string
+		expectedLength int
+	}{
+		{
+			input:          "hello",
+			expectedLength: 5,
+		},
+		{
+			input:          strings.Repeat("\U0001F468\u200d\U0001F469\u200d\U0001F467", 3),
+			expectedLength: 3,
+		},
+		{
+			input:          "\U0001F1F9\U0001F1F7 flag",
+			expectedLength: 6,
+		},
+		{
+			input:          "こんにちは",
+			expectedLength: 5,
+		},
+		{
+			input:          "read https://example.com/" + strings.Repeat("a", 200) + " now",
+			expectedLength: 5 + URLWeight + 4,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			length := Length(c.input)
+
+			if length != c.expectedLength {
+				t.Errorf("expected: %v, have got: %v\n", c.expectedLength, length)
+			}
+		})
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "hello @alice and @Bob_2", expected: []string{"alice", "bob_2"}},
+		{input: "@alice @ALICE @alice!", expected: []string{"alice"}},
+		{input: "mail me at me@example.com", expected: nil},
+		{input: "@ab is too short, @@carol is not a mention", expected: nil},
+		{input: "no mentions here", expected: nil},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			output := Mentions(c.input)
+			if strings.Join(output, ",") != strings.Join(c.expected, ",") {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "learning #Go and #golang_tips", expected: []string{"go", "golang_tips"}},
+		{input: "#go #GO #go!", expected: []string{"go"}},
+		{input: "we are #1 and #2024goals", expected: []string{"2024goals"}},
+		{input: "url.com/#anchor, &#38; and ##double", expected: nil},
+		{input: "no tags here", expected: nil},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			output := Hashtags(c.input)
+			if strings.Join(output, ",") != strings.Join(c.expected, ",") {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, output)
+			}
+		})
+	}
+}
@@ -7,29 +7,48 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, visibility, content_warning, sensitive)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	Status         string
+	PublishAt      sql.NullTime
+	Visibility     string
+	ContentWarning string
+	Sensitive      bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+		arg.PublishAt,
+		arg.Visibility,
+		arg.ContentWarning,
+		arg.Sensitive,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,25 +56,31 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ContentWarning,
+		&i.Sensitive,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1
-`
-
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
-	return err
-}
-
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive FROM chirps AS c
+WHERE c.deleted_at IS NULL
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, $1)
+AND (c.visibility IN ('public', 'followers') OR c.user_id = $1)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes AS m
+    WHERE m.muter_id = $1 AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +94,13 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ContentWarning,
+			&i.Sensitive,
 		); err != nil {
 			return nil, err
 		}
@@ -84,7 +116,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive FROM chirps WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -96,16 +128,178 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const getChirpForViewer = `-- name: GetChirpForViewer :one
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive FROM chirps AS c
+WHERE c.id = $1
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, $2)
+`
+
+type GetChirpForViewerParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+// Soft-deleted chirps are returned too so that callers can answer with a
+// tombstone; getVisibleChirp turns them into errChirpDeleted.
+func (q *Queries) GetChirpForViewer(ctx context.Context, arg GetChirpForViewerParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForViewer, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ContentWarning,
+		&i.Sensitive,
 	)
 	return i, err
 }
 
 const getChirpsByUserId = `-- name: GetChirpsByUserId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive FROM chirps AS c
+WHERE c.user_id = $1
+AND c.deleted_at IS NULL
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, $2)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes AS m
+    WHERE m.muter_id = $2 AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at ASC
+`
+
+type GetChirpsByUserIdParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsByUserId(ctx context.Context, arg GetChirpsByUserIdParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserId, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ContentWarning,
+			&i.Sensitive,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const hideReportedChirp = `-- name: HideReportedChirp :execrows
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1
+AND hidden_at IS NULL
+AND (
+    SELECT COUNT(*) FROM reports AS r
+    INNER JOIN users AS u ON u.id = r.reporter_id
+    WHERE r.chirp_id = $1
+    AND r.status <> 'resolved'
+    AND NOT u.shadow_banned
+) >= $2::INT
+`
+
+type HideReportedChirpParams struct {
+	ID        uuid.UUID
+	Threshold int32
+}
+
+// Hides the chirp once enough users have open reports against it. System
+// reports and reports by shadow-banned users do not count.
+func (q *Queries) HideReportedChirp(ctx context.Context, arg HideReportedChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideReportedChirp, arg.ID, arg.Threshold)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const liftAutomaticHide = `-- name: LiftAutomaticHide :execrows
+UPDATE chirps SET hidden_at = NULL
+WHERE id = $1
+AND hidden_at IS NOT NULL
+AND NOT EXISTS (
+    SELECT 1 FROM moderation_log AS l
+    WHERE l.chirp_id = chirps.id
+    AND l.action = 'hide_chirp'
+    AND l.created_at >= chirps.hidden_at
+)
+`
+
+// Unhides a chirp the system hid, for spam or too many reports. A hide a
+// moderator placed or confirmed with hide_chirp stays.
+func (q *Queries) LiftAutomaticHide(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, liftAutomaticHide, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps
+SET status = 'published', created_at = publish_at, updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+    ORDER BY publish_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive
 `
 
-func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserId, userID)
+func (q *Queries) PublishDueChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -119,6 +313,13 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.Visibility,
+			&i.DeletedAt,
+			&i.HiddenAt,
+			&i.ContentWarning,
+			&i.Sensitive,
 		); err != nil {
 			return nil, err
 		}
@@ -132,3 +333,169 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :many
+WITH purged AS (
+    DELETE FROM chirps
+    WHERE id IN (
+        SELECT id FROM chirps
+        WHERE deleted_at < $1
+        ORDER BY deleted_at
+        LIMIT $2
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING id
+)
+SELECT p.id, m.blob_key, m.thumbnail_key
+FROM purged AS p
+LEFT JOIN chirp_media AS m ON m.chirp_id = p.id
+`
+
+type PurgeDeletedChirpsParams struct {
+	DeletedBefore sql.NullTime
+	BatchSize     int32
+}
+
+type PurgeDeletedChirpsRow struct {
+	ID           uuid.UUID
+	BlobKey      sql.NullString
+	ThumbnailKey sql.NullString
+}
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, arg PurgeDeletedChirpsParams) ([]PurgeDeletedChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, purgeDeletedChirps, arg.DeletedBefore, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PurgeDeletedChirpsRow
+	for rows.Next() {
+		var i PurgeDeletedChirpsRow
+		if err := rows.Scan(&i.ID, &i.BlobKey, &i.ThumbnailKey); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND deleted_at > $3
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter sql.NullTime
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const setChirpSensitivity = `-- name: SetChirpSensitivity :one
+UPDATE chirps
+SET content_warning = $1, sensitive = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive
+`
+
+type SetChirpSensitivityParams struct {
+	ContentWarning string
+	Sensitive      bool
+	ID             uuid.UUID
+}
+
+func (q *Queries) SetChirpSensitivity(ctx context.Context, arg SetChirpSensitivityParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, setChirpSensitivity, arg.ContentWarning, arg.Sensitive, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteChirp, id)
+	return err
+}
+
+const updateChirpStatus = `-- name: UpdateChirpStatus :one
+UPDATE chirps
+SET status = $1,
+    publish_at = $2,
+    created_at = CASE WHEN $1::TEXT = 'published' THEN NOW() ELSE created_at END,
+    updated_at = NOW()
+WHERE id = $3 AND status <> 'published' AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, visibility, deleted_at, hidden_at, content_warning, sensitive
+`
+
+type UpdateChirpStatusParams struct {
+	Status    string
+	PublishAt sql.NullTime
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateChirpStatus(ctx context.Context, arg UpdateChirpStatusParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpStatus, arg.Status, arg.PublishAt, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.Visibility,
+		&i.DeletedAt,
+		&i.HiddenAt,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
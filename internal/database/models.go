@@ -6,17 +6,180 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	Kind      string
+	ActorID   uuid.NullUUID
+	TargetID  uuid.NullUUID
+	Ip        string
+	Metadata  json.RawMessage
+	PrevHash  []byte
+	Hash      []byte
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	Status         string
+	PublishAt      sql.NullTime
+	Visibility     string
+	DeletedAt      sql.NullTime
+	HiddenAt       sql.NullTime
+	ContentWarning string
+	Sensitive      bool
+}
+
+type ChirpMedium struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UserID               uuid.UUID
+	ChirpID              uuid.NullUUID
+	Position             int32
+	ContentType          string
+	BlobKey              string
+	ThumbnailKey         string
+	ThumbnailContentType string
+	Width                int32
+	Height               int32
+	SizeBytes            int64
+}
+
+type Conversation struct {
+	ID            uuid.UUID
+	DirectKey     sql.NullString
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	LastMessageAt sql.NullTime
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type DirectMessage struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.NullUUID
+	CreatedAt      time.Time
+	KeyID          string
+	Nonce          []byte
+	Ciphertext     []byte
+}
+
+type EntitlementGrant struct {
+	UserID     uuid.UUID
+	Capability string
+	CreatedAt  time.Time
+	GrantedBy  uuid.NullUUID
+	Reason     string
+	ExpiresAt  sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	Type        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedUntil sql.NullTime
+	LastError   string
+	UniqueKey   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  sql.NullTime
+}
+
+type ModerationLog struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	ModeratorID uuid.NullUUID
+	Action      string
+	ReportID    uuid.NullUUID
+	UserID      uuid.NullUUID
+	ChirpID     uuid.NullUUID
+	Note        string
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Type       string
+	GroupKey   string
+	ChirpID    uuid.NullUUID
+	ActorIds   []uuid.UUID
+	ActorCount int32
+	Details    string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReadAt     sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type Poll struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Label    string
+}
+
+type PollVote struct {
+	PollID    uuid.UUID
 	UserID    uuid.UUID
+	OptionID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type ProfaneTerm struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Term      string
+	Action    string
 }
 
 type RefreshToken struct {
@@ -28,11 +191,101 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Reason     string
+	Details    string
+	Status     string
+	ClaimedBy  uuid.NullUUID
+	ClaimedAt  sql.NullTime
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+	Resolution sql.NullString
+}
+
+type SpamDecision struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ChirpID   uuid.NullUUID
+	Outcome   string
+	Score     float64
+	Signals   json.RawMessage
+}
+
+type StreamEvent struct {
+	ID        int64
+	Topics    []string
+	Type      string
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	GraceUntil       time.Time
+}
+
 type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Handle          string
+	DisplayName     string
+	Bio             string
+	AvatarUrl       string
+	Role            string
+	SuspendedUntil  sql.NullTime
+	ShadowBanned    bool
+	ExpandSensitive bool
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	LastError   string
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
+	EndpointID     uuid.UUID
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      string
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Url       string
+	Secret    string
+	Events    []string
 }
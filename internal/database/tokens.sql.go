@@ -37,24 +37,32 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT token, rt.created_at, rt.updated_at, user_id, expires_at, revoked_at, id, u.created_at, u.updated_at, email, hashed_password, is_chirpy_red FROM refresh_tokens AS rt
+SELECT token, rt.created_at, rt.updated_at, user_id, expires_at, revoked_at, id, u.created_at, u.updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive FROM refresh_tokens AS rt
 INNER JOIN users AS u ON rt.user_id = u.id
 WHERE rt.token = $1
 `
 
 type GetUserFromRefreshTokenRow struct {
-	Token          string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	UserID         uuid.UUID
-	ExpiresAt      time.Time
-	RevokedAt      sql.NullTime
-	ID             uuid.UUID
-	CreatedAt_2    time.Time
-	UpdatedAt_2    time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	Token           string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          uuid.UUID
+	ExpiresAt       time.Time
+	RevokedAt       sql.NullTime
+	ID              uuid.UUID
+	CreatedAt_2     time.Time
+	UpdatedAt_2     time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Handle          string
+	DisplayName     string
+	Bio             string
+	AvatarUrl       string
+	Role            string
+	SuspendedUntil  sql.NullTime
+	ShadowBanned    bool
+	ExpandSensitive bool
 }
 
 func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
@@ -73,6 +81,14 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
@@ -87,3 +103,14 @@ func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	return err
+}
@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,6 +42,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
@@ -51,8 +63,47 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const getMentionedUserIDs = `-- name: GetMentionedUserIDs :many
+SELECT u.id FROM users AS u
+INNER JOIN chirps AS c ON c.id = $1
+WHERE LOWER(u.handle) = ANY($2::TEXT[])
+AND u.id <> c.user_id
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, u.id)
+`
+
+type GetMentionedUserIDsParams struct {
+	ChirpID uuid.UUID
+	Handles []string
+}
+
+// Resolves the handles a chirp mentions to the users who can see it, which
+// leaves out the author, users on either side of a block with them and,
+// for followers-only chirps, users who do not follow them.
+func (q *Queries) GetMentionedUserIDs(ctx context.Context, arg GetMentionedUserIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getMentionedUserIDs, arg.ChirpID, pq.Array(arg.Handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive 
 FROM users 
 WHERE email = $1
 `
@@ -67,12 +118,46 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive FROM users WHERE LOWER(handle) = LOWER($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE id = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive FROM users WHERE id = $1
 `
 
 func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
@@ -85,15 +170,130 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
 
+const getUserSummaries = `-- name: GetUserSummaries :many
+SELECT id, handle, display_name, avatar_url
+FROM users
+WHERE id = ANY($1::UUID[])
+`
+
+type GetUserSummariesRow struct {
+	ID          uuid.UUID
+	Handle      string
+	DisplayName string
+	AvatarUrl   string
+}
+
+func (q *Queries) GetUserSummaries(ctx context.Context, ids []uuid.UUID) ([]GetUserSummariesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSummaries, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserSummariesRow
+	for rows.Next() {
+		var i GetUserSummariesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.AvatarUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserExpandSensitive = `-- name: SetUserExpandSensitive :one
+UPDATE users
+SET expand_sensitive = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive
+`
+
+type SetUserExpandSensitiveParams struct {
+	ExpandSensitive bool
+	ID              uuid.UUID
+}
+
+func (q *Queries) SetUserExpandSensitive(ctx context.Context, arg SetUserExpandSensitiveParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserExpandSensitive, arg.ExpandSensitive, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
+	)
+	return i, err
+}
+
+const setUserShadowBanned = `-- name: SetUserShadowBanned :exec
+UPDATE users
+SET shadow_banned = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserShadowBannedParams struct {
+	ShadowBanned bool
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserShadowBanned(ctx context.Context, arg SetUserShadowBannedParams) error {
+	_, err := q.db.ExecContext(ctx, setUserShadowBanned, arg.ShadowBanned, arg.ID)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $1, hashed_password = $2, updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive
 `
 
 type UpdateUserParams struct {
@@ -112,22 +312,57 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
 
-const updateUserRed = `-- name: UpdateUserRed :exec
+const updateUserProfile = `-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = $1
-WHERE id = $2
+SET handle = $1, display_name = $2, bio = $3, avatar_url = $4, updated_at = NOW()
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, handle, display_name, bio, avatar_url, role, suspended_until, shadow_banned, expand_sensitive
 `
 
-type UpdateUserRedParams struct {
-	IsChirpyRed bool
+type UpdateUserProfileParams struct {
+	Handle      string
+	DisplayName string
+	Bio         string
+	AvatarUrl   string
 	ID          uuid.UUID
 }
 
-func (q *Queries) UpdateUserRed(ctx context.Context, arg UpdateUserRedParams) error {
-	_, err := q.db.ExecContext(ctx, updateUserRed, arg.IsChirpyRed, arg.ID)
-	return err
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.ExpandSensitive,
+	)
+	return i, err
 }
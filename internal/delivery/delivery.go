@@ -0,0 +1,157 @@
+// Package delivery sends signed webhook requests to endpoints that users
+// registered.
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/auth"
+)
+
+// Receivers verify SignatureHeader the same way Chirpy verifies Polka:
+// HMAC-SHA256 over "<t>.<body>" with the endpoint secret.
+const (
+	SignatureHeader = "X-Chirpy-Signature"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it is dead.
+	MaxAttempts = 8
+
+	firstRetry = 30 * time.Second
+	maxRetry   = 6 * time.Hour
+
+	maxResponseBody = 4 << 10
+)
+
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+type Request struct {
+	ID        uuid.UUID
+	URL       string
+	Secret    string
+	EventType string
+	Payload   []byte
+}
+
+// Result is the outcome of one attempt. StatusCode is zero when no
+// response arrived.
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewSender(client *http.Client) *Sender {
+	return &Sender{client: client, now: time.Now}
+}
+
+func (s *Sender) Send(ctx context.Context, req Request) Result {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", req.URL, bytes.NewReader(req.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	httpReq.Header.Set(EventHeader, req.EventType)
+	httpReq.Header.Set(DeliveryHeader, req.ID.String())
+	httpReq.Header.Set(SignatureHeader, auth.SignatureHeaderValue([]string{req.Secret}, s.now(), req.Payload))
+
+	res, err := s.client.Do(httpReq)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return Result{StatusCode: res.StatusCode, Err: fmt.Errorf("endpoint answered %v", res.Status)}
+	}
+	return Result{StatusCode: res.StatusCode}
+}
+
+// Backoff is the wait before retrying after the given number of failed
+// attempts: 30s, 1m, 2m and so on, capped at six hours.
+func Backoff(attempts int) time.Duration {
+	wait := firstRetry
+	for i := 1; i < attempts && wait < maxRetry; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRetry)
+}
+
+// ValidateURL accepts absolute https URLs without credentials whose host
+// is not an address on a private network.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("URL is invalid")
+	}
+
+	if u.Scheme != "https" || u.Host == "" {
+		return errors.New("URL must be an absolute https URL")
+	}
+
+	if u.User != nil {
+		return errors.New("URL must not contain credentials")
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublic(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// SafeClient returns a client that refuses to connect to private,
+// loopback and link-local addresses, whatever the URL's host resolves to,
+// and does not follow redirects.
+func SafeClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublic(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsMulticast()
+}
@@ -0,0 +1,161 @@
+// Package encryption seals data at rest with AES-256-GCM. Keys come from a
+// KeyProvider and every sealed value records the ID of its key, so keys
+// can be rotated without re-encrypting what was sealed before.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of an AES-256 key.
+const KeySize = 32
+
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// KeyProvider hands out the keys data is sealed with. Implementations may
+// fetch them from a key management service.
+type KeyProvider interface {
+	// Current returns the key new data is sealed with.
+	Current(ctx context.Context) (Key, error)
+	// Key returns the key with id, or ErrUnknownKey.
+	Key(ctx context.Context, id string) (Key, error)
+}
+
+// Sealed is an encrypted value along with what it takes to open it.
+type Sealed struct {
+	KeyID      string
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// Seal encrypts plaintext with the current key of kp. additionalData is
+// authenticated but not encrypted; Open needs the same to succeed, which
+// ties the sealed value to, for example, the row it is stored in.
+func Seal(ctx context.Context, kp KeyProvider, plaintext, additionalData []byte) (Sealed, error) {
+	key, err := kp.Current(ctx)
+	if err != nil {
+		return Sealed{}, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return Sealed{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Sealed{}, err
+	}
+
+	return Sealed{
+		KeyID:      key.ID,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, additionalData),
+	}, nil
+}
+
+func Open(ctx context.Context, kp KeyProvider, s Sealed, additionalData []byte) ([]byte, error) {
+	key, err := kp.Key(ctx, s.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("nonce must be %d bytes", aead.NonceSize())
+	}
+
+	return aead.Open(nil, s.Nonce, s.Ciphertext, additionalData)
+}
+
+func newAEAD(key Key) (cipher.AEAD, error) {
+	if len(key.Secret) != KeySize {
+		return nil, fmt.Errorf("key %q must be %d bytes", key.ID, KeySize)
+	}
+
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// StaticKeys is a KeyProvider with a fixed set of keys, such as ones read
+// from the environment.
+type StaticKeys struct {
+	current Key
+	keys    map[string]Key
+}
+
+// NewStaticKeys seals with the first of keys and opens with any of them.
+func NewStaticKeys(keys []Key) (*StaticKeys, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is needed")
+	}
+
+	s := &StaticKeys{current: keys[0], keys: map[string]Key{}}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key IDs must not be empty")
+		}
+		if len(key.Secret) != KeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes", key.ID, KeySize)
+		}
+		if _, ok := s.keys[key.ID]; ok {
+			return nil, fmt.Errorf("key %q is given twice", key.ID)
+		}
+		s.keys[key.ID] = key
+	}
+	return s, nil
+}
+
+func (s *StaticKeys) Current(ctx context.Context) (Key, error) {
+	return s.current, nil
+}
+
+func (s *StaticKeys) Key(ctx context.Context, id string) (Key, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// ParseKeys reads keys written as "id:base64-secret", separated by commas.
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("key %q is not of the form id:secret", entry)
+		}
+
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64", id)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return keys, nil
+}
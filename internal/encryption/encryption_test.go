@@ -0,0 +1,128 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testKey(id string, b byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{b}, KeySize)}
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	old, _ := NewStaticKeys([]Key{testKey("v1", 1)})
+	rotated, _ := NewStaticKeys([]Key{testKey("v2", 2), testKey("v1", 1)})
+	other, _ := NewStaticKeys([]Key{testKey("v2", 2)})
+
+	sealed, err := Seal(ctx, old, []byte("hello"), []byte("message-1"))
+	if err != nil {
+		t.Fatalf("Seal returned err: %v\n", err)
+	}
+
+	cases := []struct {
+		kp             KeyProvider
+		additionalData string
+		expectErr      bool
+	}{
+		{kp: old, additionalData: "message-1"},
+		{kp: rotated, additionalData: "message-1"},
+		{kp: rotated, additionalData: "message-2", expectErr: true},
+		{kp: other, additionalData: "message-1", expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			plaintext, err := Open(ctx, c.kp, sealed, []byte(c.additionalData))
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			if !c.expectErr && string(plaintext) != "hello" {
+				t.Errorf("expected: hello, have got: %q\n", plaintext)
+			}
+		})
+	}
+}
+
+func TestSealUsesCurrentKey(t *testing.T) {
+	ctx := context.Background()
+	kp, _ := NewStaticKeys([]Key{testKey("v2", 2), testKey("v1", 1)})
+
+	a, _ := Seal(ctx, kp, []byte("hello"), nil)
+	b, _ := Seal(ctx, kp, []byte("hello"), nil)
+
+	if a.KeyID != "v2" {
+		t.Errorf("expected: v2, have got: %v\n", a.KeyID)
+	}
+
+	if bytes.Equal(a.Nonce, b.Nonce) || bytes.Equal(a.Ciphertext, b.Ciphertext) {
+		t.Errorf("expected a fresh nonce for every seal\n")
+	}
+}
+
+func TestOpenUnknownKey(t *testing.T) {
+	kp, _ := NewStaticKeys([]Key{testKey("v1", 1)})
+
+	_, err := Open(context.Background(), kp, Sealed{KeyID: "v9"}, nil)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected: %v, have got: %v\n", ErrUnknownKey, err)
+	}
+}
+
+func TestNewStaticKeys(t *testing.T) {
+	cases := []struct {
+		keys      []Key
+		expectErr bool
+	}{
+		{keys: []Key{testKey("v1", 1), testKey("v2", 2)}},
+		{keys: nil, expectErr: true},
+		{keys: []Key{{ID: "v1", Secret: []byte("short")}}, expectErr: true},
+		{keys: []Key{testKey("", 1)}, expectErr: true},
+		{keys: []Key{testKey("v1", 1), testKey("v1", 2)}, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if _, err := NewStaticKeys(c.keys); (err != nil) != c.expectErr {
+				t.Errorf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))
+
+	cases := []struct {
+		input     string
+		expected  []string
+		expectErr bool
+	}{
+		{input: "v2:" + secret + ", v1:" + secret, expected: []string{"v2", "v1"}},
+		{input: "", expected: nil},
+		{input: secret, expectErr: true},
+		{input: "v1:not base64!", expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			keys, err := ParseKeys(c.input)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			var ids []string
+			for _, key := range keys {
+				ids = append(ids, key.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(c.expected) {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, ids)
+			}
+		})
+	}
+}
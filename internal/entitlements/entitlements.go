@@ -0,0 +1,99 @@
+// Package entitlements maps plans to the capabilities they unlock. Handlers
+// ask a Set whether a capability is there instead of checking the plan.
+package entitlements
+
+import (
+	"slices"
+	"sort"
+)
+
+type Capability string
+
+const (
+	LongChirps       Capability = "long_chirps"
+	EditChirps       Capability = "edit_chirps"
+	ExtraMedia       Capability = "extra_media"
+	HigherRateLimits Capability = "higher_rate_limits"
+	Polls            Capability = "polls"
+)
+
+// Capabilities lists every capability, for validating admin grants.
+var Capabilities = []Capability{
+	LongChirps,
+	EditChirps,
+	ExtraMedia,
+	HigherRateLimits,
+	Polls,
+}
+
+type Plan string
+
+const (
+	Free Plan = "free"
+	Red  Plan = "red"
+)
+
+var plans = map[Plan][]Capability{
+	Free: {},
+	Red:  {LongChirps, EditChirps, ExtraMedia, HigherRateLimits, Polls},
+}
+
+const (
+	shortChirpLength = 140
+	longChirpLength  = 280
+
+	baseMediaPerChirp  = 4
+	extraMediaPerChirp = 8
+)
+
+// Set is what one user may do: the capabilities of their plan plus any
+// that were granted to them individually.
+type Set struct {
+	Plan         Plan
+	capabilities map[Capability]bool
+}
+
+func For(plan Plan, grants []Capability) Set {
+	s := Set{Plan: plan, capabilities: map[Capability]bool{}}
+	for _, c := range plans[plan] {
+		s.capabilities[c] = true
+	}
+	for _, c := range grants {
+		s.capabilities[c] = true
+	}
+	return s
+}
+
+func (s Set) Has(c Capability) bool {
+	return s.capabilities[c]
+}
+
+// List returns the capabilities in a stable order.
+func (s Set) List() []Capability {
+	list := make([]Capability, 0, len(s.capabilities))
+	for c := range s.capabilities {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// ChirpLength is the longest body, as counted by chirptext.Length, the
+// user may post.
+func (s Set) ChirpLength() int {
+	if s.Has(LongChirps) {
+		return longChirpLength
+	}
+	return shortChirpLength
+}
+
+func (s Set) MediaPerChirp() int {
+	if s.Has(ExtraMedia) {
+		return extraMediaPerChirp
+	}
+	return baseMediaPerChirp
+}
+
+func IsCapability(c Capability) bool {
+	return slices.Contains(Capabilities, c)
+}
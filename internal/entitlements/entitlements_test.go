@@ -0,0 +1,38 @@
+package entitlements
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFor(t *testing.T) {
+	cases := []struct {
+		plan                Plan
+		grants              []Capability
+		expected            []Capability
+		expectedChirpLength int
+		expectedMedia       int
+	}{
+		{plan: Free, expected: []Capability{}, expectedChirpLength: 140, expectedMedia: 4},
+		{plan: Free, grants: []Capability{LongChirps}, expected: []Capability{LongChirps}, expectedChirpLength: 280, expectedMedia: 4},
+		{plan: Red, expected: []Capability{EditChirps, ExtraMedia, HigherRateLimits, LongChirps, Polls}, expectedChirpLength: 280, expectedMedia: 8},
+		{plan: Red, grants: []Capability{Polls}, expected: []Capability{EditChirps, ExtraMedia, HigherRateLimits, LongChirps, Polls}, expectedChirpLength: 280, expectedMedia: 8},
+		{plan: Plan("unknown"), expected: []Capability{}, expectedChirpLength: 140, expectedMedia: 4},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			s := For(c.plan, c.grants)
+
+			if got := s.List(); !slices.Equal(got, c.expected) {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+
+			if s.ChirpLength() != c.expectedChirpLength || s.MediaPerChirp() != c.expectedMedia {
+				t.Errorf("expected length %v and media %v, have got: %v and %v\n",
+					c.expectedChirpLength, c.expectedMedia, s.ChirpLength(), s.MediaPerChirp())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package jobs is a Postgres-backed queue for background work. Jobs can
+// be enqueued in the transaction that makes the change they follow up on,
+// and a Runner claims and runs them with retries, per-type concurrency
+// limits and recurring schedules.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultMaxAttempts = 10
+
+const (
+	firstRetry = 10 * time.Second
+	maxRetry   = time.Hour
+)
+
+// Job is a claimed job. Attempts counts the current run.
+type Job struct {
+	ID          uuid.UUID
+	Type        string
+	Payload     json.RawMessage
+	Attempts    int
+	MaxAttempts int
+}
+
+type NewJob struct {
+	Type    string
+	Payload any
+	// RunAt delays the job. The zero value runs it right away.
+	RunAt time.Time
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int
+	// UniqueKey, when set, makes enqueueing a second job with the same key
+	// a no-op while the first one is kept.
+	UniqueKey string
+}
+
+// Handler runs one job. Returning an error retries the job later unless
+// the error is wrapped with Permanent or the job is out of attempts.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+type Store interface {
+	Enqueue(ctx context.Context, job NewJob) (bool, error)
+	Claim(ctx context.Context, jobType string, limit int, lease time.Duration) ([]Job, error)
+	Complete(ctx context.Context, id uuid.UUID) error
+	Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastErr string) error
+	Fail(ctx context.Context, id uuid.UUID, lastErr string) error
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one that retrying will not fix.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+func isPermanent(err error) bool {
+	var perm permanentError
+	return errors.As(err, &perm)
+}
+
+// Backoff is the wait before the next run after the given number of
+// attempts: 10s, 20s, 40s and so on, capped at an hour.
+func Backoff(attempts int) time.Duration {
+	wait := firstRetry
+	for i := 1; i < attempts && wait < maxRetry; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRetry)
+}
@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+// PostgresStore keeps jobs in the jobs table, shared by every instance.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Enqueue inserts job with q, which may be bound to a transaction. The job
+// is then only visible to workers once that transaction commits. It
+// reports false when a job with the same UniqueKey already exists.
+func Enqueue(ctx context.Context, q *database.Queries, job NewJob) (bool, error) {
+	payload, err := json.Marshal(job.Payload)
+	if err != nil {
+		return false, err
+	}
+
+	runAt := job.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	maxAttempts := job.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	inserted, err := q.EnqueueJob(ctx, database.EnqueueJobParams{
+		Type:        job.Type,
+		Payload:     payload,
+		MaxAttempts: int32(maxAttempts),
+		RunAt:       runAt.UTC(),
+		UniqueKey:   sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""},
+	})
+	return inserted == 1, err
+}
+
+func (s *PostgresStore) Enqueue(ctx context.Context, job NewJob) (bool, error) {
+	return Enqueue(ctx, s.db, job)
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, jobType string, limit int, lease time.Duration) ([]Job, error) {
+	rows, err := s.db.ClaimJobs(ctx, database.ClaimJobsParams{
+		LeaseSeconds: lease.Seconds(),
+		Type:         jobType,
+		BatchSize:    int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]Job, 0, len(rows))
+	for _, row := range rows {
+		claimed = append(claimed, Job{
+			ID:          row.ID,
+			Type:        row.Type,
+			Payload:     row.Payload,
+			Attempts:    int(row.Attempts),
+			MaxAttempts: int(row.MaxAttempts),
+		})
+	}
+	return claimed, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, id uuid.UUID) error {
+	return s.db.CompleteJob(ctx, id)
+}
+
+func (s *PostgresStore) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastErr string) error {
+	return s.db.RetryJob(ctx, database.RetryJobParams{
+		LastError: lastErr,
+		RunAt:     runAt.UTC(),
+		ID:        id,
+	})
+}
+
+func (s *PostgresStore) Fail(ctx context.Context, id uuid.UUID, lastErr string) error {
+	return s.db.FailJob(ctx, database.FailJobParams{
+		LastError: lastErr,
+		ID:        id,
+	})
+}
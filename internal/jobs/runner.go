@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// PollInterval is how often the runner looks for due jobs.
+	PollInterval time.Duration
+	// Lease is how long a claimed job may run before another instance
+	// may claim it again. Handlers are cancelled when it runs out.
+	Lease time.Duration
+	// DrainTimeout is how long Run waits for running jobs on shutdown
+	// before it cancels them.
+	DrainTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		DrainTimeout: 30 * time.Second,
+	}
+}
+
+// resultTimeout bounds recording a job's outcome, which also happens
+// while draining after the handlers were cancelled.
+const resultTimeout = 5 * time.Second
+
+type worker struct {
+	handler     Handler
+	concurrency int
+	running     int
+}
+
+type schedule struct {
+	jobType string
+	every   time.Duration
+}
+
+type Runner struct {
+	store   Store
+	cfg     Config
+	now     func() time.Time
+	workers map[string]*worker
+
+	schedules []schedule
+
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+func NewRunner(store Store, cfg Config) *Runner {
+	return &Runner{
+		store:   store,
+		cfg:     cfg,
+		now:     time.Now,
+		workers: map[string]*worker{},
+	}
+}
+
+// Register runs jobs of jobType with h, at most concurrency at a time in
+// this instance. It must be called before Run.
+func (r *Runner) Register(jobType string, concurrency int, h Handler) {
+	r.workers[jobType] = &worker{handler: h, concurrency: max(concurrency, 1)}
+}
+
+// Schedule enqueues a job of jobType once every interval, aligned to the
+// clock. The slot is part of the job's unique key, so every instance can
+// call Schedule and a slot still runs once.
+func (r *Runner) Schedule(jobType string, every time.Duration) {
+	r.schedules = append(r.schedules, schedule{jobType: jobType, every: every})
+}
+
+// Run claims and runs jobs until ctx is cancelled. It then stops claiming
+// and waits up to DrainTimeout for running jobs, cancelling whatever is
+// still running after that. Jobs that did not finish are claimed again
+// once their lease runs out.
+func (r *Runner) Run(ctx context.Context) {
+	work, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.enqueueScheduled(ctx)
+		r.poll(ctx, work)
+
+		select {
+		case <-ctx.Done():
+			r.drain(cancelWork)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) enqueueScheduled(ctx context.Context) {
+	now := r.now()
+	for _, s := range r.schedules {
+		slot := now.Truncate(s.every)
+		_, err := r.store.Enqueue(ctx, NewJob{
+			Type:      s.jobType,
+			RunAt:     slot,
+			UniqueKey: fmt.Sprintf("schedule:%s:%d", s.jobType, slot.Unix()),
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Could not schedule %v job: %v\n", s.jobType, err)
+		}
+	}
+}
+
+// poll claims as many jobs of each type as the type has free slots.
+func (r *Runner) poll(ctx, work context.Context) {
+	types := make([]string, 0, len(r.workers))
+	for jobType := range r.workers {
+		types = append(types, jobType)
+	}
+	slices.Sort(types)
+
+	for _, jobType := range types {
+		w := r.workers[jobType]
+
+		r.mu.Lock()
+		free := w.concurrency - w.running
+		r.mu.Unlock()
+		if free <= 0 {
+			continue
+		}
+
+		claimed, err := r.store.Claim(ctx, jobType, free, r.cfg.Lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Could not claim %v jobs: %v\n", jobType, err)
+			}
+			continue
+		}
+
+		for _, job := range claimed {
+			r.mu.Lock()
+			w.running++
+			r.mu.Unlock()
+
+			r.wg.Add(1)
+			go r.execute(work, w, job)
+		}
+	}
+}
+
+func (r *Runner) execute(work context.Context, w *worker, job Job) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		w.running--
+		r.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(work, r.cfg.Lease)
+	err := runHandler(ctx, w.handler, job)
+	cancel()
+
+	resultCtx, cancel := context.WithTimeout(context.WithoutCancel(work), resultTimeout)
+	defer cancel()
+
+	switch {
+	case err == nil:
+		err = r.store.Complete(resultCtx, job.ID)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %v (%v) failed: %v\n", job.ID, job.Type, err)
+		err = r.store.Fail(resultCtx, job.ID, err.Error())
+	default:
+		err = r.store.Retry(resultCtx, job.ID, r.now().Add(Backoff(job.Attempts)), err.Error())
+	}
+	if err != nil {
+		log.Printf("Could not record result of job %v: %v\n", job.ID, err)
+	}
+}
+
+// runHandler turns a panicking handler into a failed attempt.
+func runHandler(ctx context.Context, h Handler, job Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job.Payload)
+}
+
+func (r *Runner) drain(cancelWork context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(r.cfg.DrainTimeout):
+		log.Println("Jobs did not finish in time, cancelling them")
+		cancelWork()
+		<-done
+	}
+}
@@ -0,0 +1,324 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type storedJob struct {
+	job       Job
+	status    string
+	runAt     time.Time
+	uniqueKey string
+	lastErr   string
+}
+
+// memoryStore is a Store for tests. It claims in insertion order and
+// ignores leases.
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs []*storedJob
+}
+
+func (s *memoryStore) Enqueue(ctx context.Context, job NewJob) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if job.UniqueKey != "" && j.uniqueKey == job.UniqueKey {
+			return false, nil
+		}
+	}
+
+	payload, _ := json.Marshal(job.Payload)
+	s.jobs = append(s.jobs, &storedJob{
+		job:       Job{ID: uuid.New(), Type: job.Type, Payload: payload, MaxAttempts: max(job.MaxAttempts, 1)},
+		status:    "pending",
+		runAt:     job.RunAt,
+		uniqueKey: job.UniqueKey,
+	})
+	return true, nil
+}
+
+func (s *memoryStore) Claim(ctx context.Context, jobType string, limit int, lease time.Duration) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []Job
+	for _, j := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if j.job.Type == jobType && j.status == "pending" && !j.runAt.After(time.Now()) {
+			j.status = "running"
+			j.job.Attempts++
+			claimed = append(claimed, j.job)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) set(id uuid.UUID, status string, runAt time.Time, lastErr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.job.ID == id {
+			j.status, j.lastErr = status, lastErr
+			if !runAt.IsZero() {
+				j.runAt = runAt
+			}
+			return nil
+		}
+	}
+	return errors.New("no such job")
+}
+
+func (s *memoryStore) Complete(ctx context.Context, id uuid.UUID) error {
+	return s.set(id, "done", time.Time{}, "")
+}
+
+func (s *memoryStore) Retry(ctx context.Context, id uuid.UUID, runAt time.Time, lastErr string) error {
+	return s.set(id, "pending", runAt, lastErr)
+}
+
+func (s *memoryStore) Fail(ctx context.Context, id uuid.UUID, lastErr string) error {
+	return s.set(id, "failed", time.Time{}, lastErr)
+}
+
+func (s *memoryStore) busy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.status == "running" || (j.status == "pending" && !j.runAt.After(time.Now())) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) statuses() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]string, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	return statuses
+}
+
+func testConfig() Config {
+	return Config{PollInterval: 5 * time.Millisecond, Lease: time.Second, DrainTimeout: time.Second}
+}
+
+// runUntil runs r until no job in store is running or due, then stops it.
+func runUntil(t *testing.T, r *Runner, store *memoryStore) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if !store.busy() {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestRunnerConcurrencyLimit(t *testing.T) {
+	store := &memoryStore{}
+	for range 6 {
+		store.Enqueue(context.Background(), NewJob{Type: "email"})
+	}
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+
+	r := NewRunner(store, testConfig())
+	r.Register("email", 2, func(ctx context.Context, payload json.RawMessage) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+
+	runUntil(t, r, store)
+
+	if peak != 2 {
+		t.Errorf("expected at most 2 concurrent jobs, have got: %v\n", peak)
+	}
+
+	for i, status := range store.statuses() {
+		if status != "done" {
+			t.Errorf("expected job %d to be done, have got: %v\n", i+1, status)
+		}
+	}
+}
+
+func TestRunnerOutcomes(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := []struct {
+		err            error
+		maxAttempts    int
+		expectedStatus string
+	}{
+		{err: nil, maxAttempts: 3, expectedStatus: "done"},
+		{err: errBoom, maxAttempts: 3, expectedStatus: "pending"},
+		{err: errBoom, maxAttempts: 1, expectedStatus: "failed"},
+		{err: Permanent(errBoom), maxAttempts: 3, expectedStatus: "failed"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			store := &memoryStore{}
+			store.Enqueue(context.Background(), NewJob{Type: "purge", MaxAttempts: c.maxAttempts})
+
+			r := NewRunner(store, testConfig())
+			r.Register("purge", 1, func(ctx context.Context, payload json.RawMessage) error {
+				return c.err
+			})
+
+			// A retried job is due after the backoff, so it stays pending.
+			runUntil(t, r, store)
+
+			store.mu.Lock()
+			job := store.jobs[0]
+			store.mu.Unlock()
+
+			if job.status != c.expectedStatus {
+				t.Errorf("expected: %v, have got: %v (%v)\n", c.expectedStatus, job.status, job.lastErr)
+			}
+
+			if c.expectedStatus == "pending" && job.runAt.Before(time.Now().Add(Backoff(1)/2)) {
+				t.Errorf("expected the retry to be delayed, have got: %v\n", job.runAt)
+			}
+		})
+	}
+}
+
+func TestRunnerPanicIsRetried(t *testing.T) {
+	store := &memoryStore{}
+	store.Enqueue(context.Background(), NewJob{Type: "fanout", MaxAttempts: 3})
+
+	r := NewRunner(store, testConfig())
+	r.Register("fanout", 1, func(ctx context.Context, payload json.RawMessage) error {
+		panic("nil map")
+	})
+
+	runUntil(t, r, store)
+
+	if status := store.statuses()[0]; status != "pending" {
+		t.Errorf("expected: pending, have got: %v\n", status)
+	}
+}
+
+func TestRunnerDrain(t *testing.T) {
+	cases := []struct {
+		jobTime        time.Duration
+		drainTimeout   time.Duration
+		expectedStatus string
+	}{
+		{jobTime: 50 * time.Millisecond, drainTimeout: time.Second, expectedStatus: "done"},
+		{jobTime: time.Minute, drainTimeout: 50 * time.Millisecond, expectedStatus: "pending"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			store := &memoryStore{}
+			store.Enqueue(context.Background(), NewJob{Type: "slow", MaxAttempts: 3})
+
+			cfg := testConfig()
+			cfg.DrainTimeout = c.drainTimeout
+
+			started := make(chan struct{})
+			r := NewRunner(store, cfg)
+			r.Register("slow", 1, func(ctx context.Context, payload json.RawMessage) error {
+				close(started)
+				select {
+				case <-time.After(c.jobTime):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				r.Run(ctx)
+				close(done)
+			}()
+
+			<-started
+			cancel()
+			<-done
+
+			if status := store.statuses()[0]; status != c.expectedStatus {
+				t.Errorf("expected: %v, have got: %v\n", c.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestScheduleEnqueuesOncePerSlot(t *testing.T) {
+	store := &memoryStore{}
+	now := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	r := NewRunner(store, testConfig())
+	r.now = func() time.Time { return now }
+	r.Schedule("cleanup", time.Hour)
+
+	r.enqueueScheduled(context.Background())
+	r.enqueueScheduled(context.Background())
+	now = now.Add(time.Hour)
+	r.enqueueScheduled(context.Background())
+
+	if n := len(store.statuses()); n != 2 {
+		t.Errorf("expected 2 jobs, have got: %v\n", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{attempts: 1, expected: 10 * time.Second},
+		{attempts: 3, expected: 40 * time.Second},
+		{attempts: 50, expected: time.Hour},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if got := Backoff(c.attempts); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
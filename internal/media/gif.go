@@ -0,0 +1,92 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MaxGIFFrames and MaxGIFPixels bound what decoding an animation may
+	// allocate. Each frame decodes to one byte per pixel of its own size,
+	// however well it compressed.
+	MaxGIFFrames = 500
+	MaxGIFPixels = 100_000_000
+)
+
+var errTruncatedGIF = errors.New("gif: truncated file")
+
+// checkGIF walks the block structure of a GIF without decompressing any
+// frame and rejects files with too many frames or too many pixels in
+// total.
+func checkGIF(data []byte) error {
+	// Header and logical screen descriptor.
+	if len(data) < 13 {
+		return errTruncatedGIF
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+
+	frames, pixels := 0, 0
+	for {
+		if pos >= len(data) {
+			return errTruncatedGIF
+		}
+
+		switch data[pos] {
+		case 0x21: // Extension: label, then data sub-blocks.
+			var err error
+			if pos, err = skipSubBlocks(data, pos+2); err != nil {
+				return err
+			}
+		case 0x2C: // Image descriptor.
+			if pos+10 > len(data) {
+				return errTruncatedGIF
+			}
+			w := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			h := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+
+			frames++
+			pixels += w * h
+			if frames > MaxGIFFrames {
+				return fmt.Errorf("gif has more than %d frames", MaxGIFFrames)
+			}
+			if pixels > MaxGIFPixels {
+				return fmt.Errorf("gif frames have more than %d pixels in total", MaxGIFPixels)
+			}
+
+			// LZW minimum code size, then the compressed sub-blocks.
+			var err error
+			if pos, err = skipSubBlocks(data, pos+1); err != nil {
+				return err
+			}
+		case 0x3B: // Trailer.
+			return nil
+		default:
+			return fmt.Errorf("gif: unknown block type 0x%02x", data[pos])
+		}
+	}
+}
+
+// skipSubBlocks returns the position after the sub-blocks starting at pos,
+// which end with an empty block.
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errTruncatedGIF
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
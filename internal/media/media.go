@@ -0,0 +1,171 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	MaxUploadSize = 10 << 20
+	MaxDimension  = 8192
+	// MaxPixels bounds the decoded size of an image, about 100 MiB as
+	// RGBA. A well-compressed upload can be far smaller than that.
+	MaxPixels        = 25_000_000
+	ThumbnailSize    = 320
+	jpegQuality      = 90
+	thumbnailQuality = 80
+)
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// Image is an upload that has been validated and re-encoded. Re-encoding
+// from decoded pixels drops EXIF and any other embedded metadata.
+type Image struct {
+	ContentType          string
+	Data                 []byte
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Process sniffs the upload's real content type, ignoring whatever the
+// client claimed, and produces a clean copy plus a thumbnail.
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+
+	if conf.Width <= 0 || conf.Height <= 0 || conf.Width > MaxDimension || conf.Height > MaxDimension {
+		return Image{}, fmt.Errorf("image dimensions %dx%d are out of range", conf.Width, conf.Height)
+	}
+
+	if conf.Width*conf.Height > MaxPixels {
+		return Image{}, fmt.Errorf("image has more than %d pixels", MaxPixels)
+	}
+
+	out := Image{
+		ContentType: contentType,
+		Width:       conf.Width,
+		Height:      conf.Height,
+	}
+
+	var first image.Image
+	buf := bytes.Buffer{}
+
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, err
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Image{}, err
+		}
+		first = img
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, err
+		}
+		if err := png.Encode(&buf, img); err != nil {
+			return Image{}, err
+		}
+		first = img
+	case "image/gif":
+		// Keep every frame so animations survive; comment and application
+		// extensions are not written back out.
+		if err := checkGIF(data); err != nil {
+			return Image{}, err
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, err
+		}
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			return Image{}, err
+		}
+		first = g.Image[0]
+	}
+	out.Data = buf.Bytes()
+
+	thumb := Thumbnail(first, ThumbnailSize)
+	thumbBuf := bytes.Buffer{}
+	if contentType == "image/jpeg" {
+		out.ThumbnailContentType = "image/jpeg"
+		err = jpeg.Encode(&thumbBuf, thumb, &jpeg.Options{Quality: thumbnailQuality})
+	} else {
+		out.ThumbnailContentType = "image/png"
+		err = png.Encode(&thumbBuf, thumb)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	out.Thumbnail = thumbBuf.Bytes()
+
+	return out, nil
+}
+
+// Thumbnail scales src down so that neither side exceeds size, averaging
+// the source pixels that fall into each destination pixel. Images that
+// already fit are copied as is.
+func Thumbnail(src image.Image, size int) *image.NRGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := b.Min.Y + y*h/th
+		y1 := max(y0+1, b.Min.Y+(y+1)*h/th)
+		for x := 0; x < tw; x++ {
+			x0 := b.Min.X + x*w/tw
+			x1 := max(x0+1, b.Min.X+(x+1)*w/tw)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(src.At(sx, sy)).(color.NRGBA)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / n),
+				G: uint8(g / n),
+				B: uint8(bl / n),
+				A: uint8(a / n),
+			})
+		}
+	}
+
+	return dst
+}
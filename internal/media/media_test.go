@@ -0,0 +1,168 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("could not encode png: %v\n", err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader is the start of a PNG of the given size, which is all that
+// image.DecodeConfig reads.
+func pngHeader(w, h int) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(w))
+	ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(h))
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA, no interlacing
+
+	data := []byte("\x89PNG\r\n\x1a\n")
+	data = binary.BigEndian.AppendUint32(data, uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestProcessRejectsLargeImages(t *testing.T) {
+	cases := []struct {
+		width       int
+		height      int
+		expectedErr string
+	}{
+		{width: MaxDimension + 1, height: 1, expectedErr: "out of range"},
+		{width: 1, height: MaxDimension + 1, expectedErr: "out of range"},
+		{width: MaxDimension, height: MaxDimension, expectedErr: "pixels"},
+		{width: 5000, height: MaxPixels/5000 + 1, expectedErr: "pixels"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			_, err := Process(pngHeader(c.width, c.height))
+			if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+				t.Errorf("expected an error about %v, have got: %v\n", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestProcessPNG(t *testing.T) {
+	out, err := Process(encodePNG(t, 640, 480))
+	if err != nil {
+		t.Fatalf("Process returned err: %v\n", err)
+	}
+
+	if out.ContentType != "image/png" || out.Width != 640 || out.Height != 480 {
+		t.Fatalf("unexpected result: %v %dx%d\n", out.ContentType, out.Width, out.Height)
+	}
+
+	thumb, err := png.Decode(bytes.NewReader(out.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail is not a png: %v\n", err)
+	}
+
+	if b := thumb.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != 240 {
+		t.Fatalf("expected thumbnail 320x240, got %dx%d\n", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessStripsEXIF(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("could not encode jpeg: %v\n", err)
+	}
+
+	// Splice an APP1 "Exif" segment right after the SOI marker.
+	exif := []byte{0xFF, 0xE1, 0x00, 0x0E, 'E', 'x', 'i', 'f', 0, 0, 'G', 'P', 'S', '!', '!', '!'}
+	src := append([]byte{0xFF, 0xD8}, exif...)
+	src = append(src, buf.Bytes()[2:]...)
+
+	out, err := Process(src)
+	if err != nil {
+		t.Fatalf("Process returned err: %v\n", err)
+	}
+
+	if bytes.Contains(out.Data, []byte("Exif")) || bytes.Contains(out.Data, []byte("GPS!!!")) {
+		t.Fatalf("EXIF segment survived processing")
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	_, err := Process([]byte("<html><body>definitely a png</body></html>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("Process must have returned ErrUnsupportedType, instead got: %v", err)
+	}
+}
+
+func encodeGIF(t *testing.T, frames, w, h int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette))
+		g.Delay = append(g.Delay, 10)
+	}
+
+	buf := bytes.Buffer{}
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("could not encode gif: %v\n", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessGIFLimits(t *testing.T) {
+	cases := []struct {
+		frames    int
+		size      int
+		expectErr bool
+	}{
+		{frames: 3, size: 64},
+		{frames: MaxGIFFrames + 1, size: 1, expectErr: true},
+		// Few frames, but too many pixels once decoded.
+		{frames: MaxGIFPixels/(4000*4000) + 1, size: 4000, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			out, err := Process(encodeGIF(t, c.frames, c.size, c.size))
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			if !c.expectErr && out.ContentType != "image/gif" {
+				t.Errorf("expected: image/gif, have got: %v\n", out.ContentType)
+			}
+		})
+	}
+}
+
+func TestCheckGIFTruncated(t *testing.T) {
+	data := encodeGIF(t, 2, 16, 16)
+
+	if err := checkGIF(data[:len(data)-5]); err == nil {
+		t.Errorf("expected a truncated gif to be rejected\n")
+	}
+}
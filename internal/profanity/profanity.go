@@ -0,0 +1,218 @@
+// Package profanity matches chirp text against an admin-managed word list.
+//
+// Matching works on Unicode word boundaries and compares canonical forms,
+// so "F0RN@X", "fornаx" with a Cyrillic "а" and "fórnax" all match the term
+// "fornax".
+package profanity
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces the matched word with Mask.
+	ActionMask Action = "mask"
+	// ActionReject refuses the whole text.
+	ActionReject Action = "reject"
+	// ActionFlag keeps the text as is and reports the term for review.
+	ActionFlag Action = "flag"
+)
+
+const Mask = "****"
+
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionMask, ActionReject, ActionFlag:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown action %q", s)
+	}
+}
+
+type Term struct {
+	Term   string
+	Action Action
+}
+
+// Result describes what Check found. Text has every masked term replaced;
+// Flagged lists the canonical form of every flagged term.
+type Result struct {
+	Text     string
+	Rejected bool
+	Flagged  []string
+}
+
+// Filter is safe for concurrent use. Replace swaps the term list while
+// Check calls are running.
+type Filter struct {
+	mu    sync.RWMutex
+	terms map[string]Action
+}
+
+func New(terms []Term) *Filter {
+	f := &Filter{}
+	f.Replace(terms)
+	return f
+}
+
+func (f *Filter) Replace(terms []Term) {
+	m := make(map[string]Action, len(terms))
+	for _, t := range terms {
+		if c := Canonical(t.Term); c != "" {
+			m[c] = t.Action
+		}
+	}
+
+	f.mu.Lock()
+	f.terms = m
+	f.mu.Unlock()
+}
+
+// ValidateTerm checks that a term can be matched at all: it must be a
+// single word with at least one letter or digit.
+func ValidateTerm(term string) error {
+	if strings.IndexFunc(term, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("terms must be a single word")
+	}
+	if Canonical(term) == "" {
+		return fmt.Errorf("terms need at least one letter or digit")
+	}
+	return nil
+}
+
+type span struct {
+	start, end int
+}
+
+func (f *Filter) Check(text string) Result {
+	f.mu.RLock()
+	terms := f.terms
+	f.mu.RUnlock()
+
+	res := Result{}
+	var masked []span
+	seen := map[string]bool{}
+
+	match := func(start, end int, canonical string) bool {
+		action, ok := terms[canonical]
+		if !ok {
+			return false
+		}
+
+		switch action {
+		case ActionMask:
+			masked = append(masked, span{start, end})
+		case ActionReject:
+			res.Rejected = true
+		case ActionFlag:
+			if !seen[canonical] {
+				seen[canonical] = true
+				res.Flagged = append(res.Flagged, canonical)
+			}
+		}
+		return true
+	}
+
+	for _, chunk := range chunks(text) {
+		matched := false
+		for _, w := range words(text, chunk) {
+			if match(w.start, w.end, Canonical(text[w.start:w.end])) {
+				matched = true
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// Separators and leetspeak symbols such as "@" split a word in
+		// several segments, so also try the whole chunk, with and without
+		// its surrounding punctuation.
+		core := trimPunctuation(text, chunk)
+		if core.end > core.start && !match(core.start, core.end, Canonical(text[core.start:core.end])) {
+			match(core.start, core.end, Canonical(text[chunk.start:chunk.end]))
+		}
+	}
+
+	res.Text = mask(text, masked)
+	return res
+}
+
+// chunks splits text on white space.
+func chunks(text string) []span {
+	var out []span
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				out = append(out, span{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		out = append(out, span{start, len(text)})
+	}
+	return out
+}
+
+// words returns the word segments of a chunk that contain a letter or a
+// digit, following the Unicode word boundary rules.
+func words(text string, chunk span) []span {
+	var out []span
+	rest := text[chunk.start:chunk.end]
+	offset := chunk.start
+	state := -1
+	for len(rest) > 0 {
+		var word string
+		word, rest, state = uniseg.FirstWordInString(rest, state)
+		if strings.IndexFunc(word, isWordRune) >= 0 {
+			out = append(out, span{offset, offset + len(word)})
+		}
+		offset += len(word)
+	}
+	return out
+}
+
+func trimPunctuation(text string, chunk span) span {
+	s := text[chunk.start:chunk.end]
+	start := strings.IndexFunc(s, isWordRune)
+	if start < 0 {
+		return span{chunk.start, chunk.start}
+	}
+	end := strings.LastIndexFunc(s, isWordRune)
+	_, size := utf8.DecodeRuneInString(s[end:])
+	return span{chunk.start + start, chunk.start + end + size}
+}
+
+func mask(text string, spans []span) string {
+	if len(spans) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last {
+			continue
+		}
+		b.WriteString(text[last:s.start])
+		b.WriteString(Mask)
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
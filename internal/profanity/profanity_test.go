@@ -0,0 +1,142 @@
+package profanity
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	filter := New([]Term{
+		{Term: "fornax", Action: ActionMask},
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionFlag},
+		{Term: "blorp", Action: ActionReject},
+	})
+
+	cases := []struct {
+		input            string
+		expectedText     string
+		expectedRejected bool
+		expectedFlagged  []string
+	}{
+		{
+			input:        "Nothing to see here",
+			expectedText: "Nothing to see here",
+		},
+		{
+			input:        "Fornax! and (kerfuffle).",
+			expectedText: "****! and (****).",
+		},
+		{
+			input:        "F0RN@X, f.o.r.n.a.x and fórnax",
+			expectedText: "****, **** and ****",
+		},
+		{
+			// Cyrillic letters standing in for Latin ones.
+			input:        "f\u043ernax and fornax\u0430l",
+			expectedText: "**** and fornax\u0430l",
+		},
+		{
+			input:           "a sharbert and another SHARBERT",
+			expectedText:    "a sharbert and another SHARBERT",
+			expectedFlagged: []string{"sharbert"},
+		},
+		{
+			input:            "bl0rp",
+			expectedText:     "bl0rp",
+			expectedRejected: true,
+		},
+		{
+			input:        "fornaxes and afornax are other words",
+			expectedText: "fornaxes and afornax are other words",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			res := filter.Check(c.input)
+
+			if res.Text != c.expectedText {
+				t.Errorf("expected text %q, have got: %q\n", c.expectedText, res.Text)
+			}
+			if res.Rejected != c.expectedRejected {
+				t.Errorf("expected rejected: %v, have got: %v\n", c.expectedRejected, res.Rejected)
+			}
+			if !reflect.DeepEqual(res.Flagged, c.expectedFlagged) {
+				t.Errorf("expected flagged: %v, have got: %v\n", c.expectedFlagged, res.Flagged)
+			}
+		})
+	}
+}
+
+func TestCheckMasksWholeWords(t *testing.T) {
+	filter := New([]Term{
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionMask},
+		{Term: "fornax", Action: ActionMask},
+	})
+
+	cases := []struct {
+		input        string
+		expectedText string
+	}{
+		{
+			input:        "I had something interesting for breakfast",
+			expectedText: "I had something interesting for breakfast",
+		},
+		{
+			input:        "I hear Mastodon is better than Chirpy. sharbert I need to migrate",
+			expectedText: "I hear Mastodon is better than Chirpy. **** I need to migrate",
+		},
+		{
+			input:        "I really need a kerfuffle to go to bed sooner, Fornax !",
+			expectedText: "I really need a **** to go to bed sooner, **** !",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			res := filter.Check(c.input)
+
+			if res.Text != c.expectedText {
+				t.Errorf("expected text %q, have got: %q\n", c.expectedText, res.Text)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	filter := New(nil)
+	if res := filter.Check("fornax"); res.Text != "fornax" {
+		t.Fatalf("expected no match before Replace, have got: %q\n", res.Text)
+	}
+
+	filter.Replace([]Term{{Term: "Fornax", Action: ActionMask}})
+	if res := filter.Check("fornax"); res.Text != Mask {
+		t.Fatalf("expected a match after Replace, have got: %q\n", res.Text)
+	}
+}
+
+func TestValidateTerm(t *testing.T) {
+	cases := []struct {
+		input     string
+		expectErr bool
+	}{
+		{input: "fornax", expectErr: false},
+		{input: "h4x0r", expectErr: false},
+		{input: "two words", expectErr: true},
+		{input: "...", expectErr: true},
+		{input: "", expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			err := ValidateTerm(c.input)
+
+			if (err != nil) != c.expectErr {
+				t.Errorf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+		})
+	}
+}
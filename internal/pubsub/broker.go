@@ -0,0 +1,138 @@
+// Package pubsub fans real-time events out to the clients connected to
+// this instance. Events are stored in Postgres and relayed to every
+// instance with LISTEN/NOTIFY.
+package pubsub
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+type Event struct {
+	ID     int64
+	Topics []string
+	Type   string
+	Data   json.RawMessage
+}
+
+// Broker delivers published events to the subscriptions of any of their
+// topics. Publish never blocks: a subscriber whose buffer is full is
+// dropped and has to resume from the last event it received.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: map[*Subscription]struct{}{}}
+}
+
+type Subscription struct {
+	broker *Broker
+	events chan Event
+
+	// Guarded by broker.mu.
+	topics map[string]bool
+	lagged bool
+	done   bool
+}
+
+// Subscribe listens to topics with room for buffer undelivered events. On
+// a closed broker the subscription starts out closed.
+func (b *Broker) Subscribe(topics []string, buffer int) *Subscription {
+	sub := &Subscription{
+		broker: b,
+		events: make(chan Event, buffer),
+		topics: map[string]bool{},
+	}
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		sub.done = true
+		close(sub.events)
+		return sub
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if !slices.ContainsFunc(e.Topics, func(topic string) bool { return sub.topics[topic] }) {
+			continue
+		}
+
+		select {
+		case sub.events <- e:
+		default:
+			sub.lagged = true
+			b.remove(sub)
+		}
+	}
+}
+
+// Close ends every subscription, which lets streaming handlers return
+// when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if sub.done {
+		return
+	}
+	sub.done = true
+	delete(b.subs, sub)
+	close(sub.events)
+}
+
+// Events is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Lagged reports whether the subscription was dropped because its buffer
+// filled up.
+func (s *Subscription) Lagged() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.lagged
+}
+
+// Add starts listening to topic as well.
+func (s *Subscription) Add(topic string) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.topics[topic] = true
+}
+
+// Remove stops listening to topic. Events of it that were already buffered
+// are still delivered.
+func (s *Subscription) Remove(topic string) {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	delete(s.topics, topic)
+}
+
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
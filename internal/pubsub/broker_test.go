@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"fmt"
+	"testing"
+)
+
+func drain(sub *Subscription) []int64 {
+	var ids []int64
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return ids
+			}
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestBrokerTopics(t *testing.T) {
+	cases := []struct {
+		topics   []string
+		expected []int64
+	}{
+		{topics: []string{"chirps"}, expected: []int64{1, 2}},
+		{topics: []string{"user:alice"}, expected: []int64{1}},
+		{topics: []string{"user:alice", "user:bob"}, expected: []int64{1, 2}},
+		{topics: []string{"notifications:alice"}, expected: []int64{3}},
+		{topics: nil, expected: nil},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			b := NewBroker()
+			sub := b.Subscribe(c.topics, 10)
+
+			b.Publish(Event{ID: 1, Topics: []string{"chirps", "user:alice"}})
+			b.Publish(Event{ID: 2, Topics: []string{"chirps", "user:bob"}})
+			b.Publish(Event{ID: 3, Topics: []string{"notifications:alice"}})
+
+			ids := drain(sub)
+			if fmt.Sprint(ids) != fmt.Sprint(c.expected) {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, ids)
+			}
+		})
+	}
+}
+
+func TestSubscriptionAddRemove(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(nil, 10)
+
+	b.Publish(Event{ID: 1, Topics: []string{"hashtag:go"}})
+	sub.Add("hashtag:go")
+	b.Publish(Event{ID: 2, Topics: []string{"hashtag:go"}})
+	sub.Remove("hashtag:go")
+	b.Publish(Event{ID: 3, Topics: []string{"hashtag:go"}})
+
+	if ids := drain(sub); fmt.Sprint(ids) != "[2]" {
+		t.Errorf("expected: [2], have got: %v\n", ids)
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe([]string{"chirps"}, 2)
+	fast := b.Subscribe([]string{"chirps"}, 10)
+
+	for id := int64(1); id <= 3; id++ {
+		b.Publish(Event{ID: id, Topics: []string{"chirps"}})
+	}
+
+	if !slow.Lagged() {
+		t.Errorf("expected the slow subscriber to lag\n")
+	}
+
+	if ids := drain(slow); fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("expected the buffered events before the channel closed, have got: %v\n", ids)
+	}
+
+	if _, ok := <-slow.Events(); ok {
+		t.Errorf("expected the slow subscription to be closed\n")
+	}
+
+	if fast.Lagged() || len(drain(fast)) != 3 {
+		t.Errorf("expected the fast subscriber to get every event\n")
+	}
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe([]string{"chirps"}, 1)
+	b.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("expected the subscription to be closed\n")
+	}
+
+	if sub.Lagged() {
+		t.Errorf("expected a closed broker not to count as lag\n")
+	}
+
+	late := b.Subscribe([]string{"chirps"}, 1)
+	if _, ok := <-late.Events(); ok {
+		t.Errorf("expected subscribing to a closed broker to return a closed subscription\n")
+	}
+
+	sub.Close()
+	b.Publish(Event{ID: 1, Topics: []string{"chirps"}})
+}
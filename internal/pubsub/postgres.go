@@ -0,0 +1,120 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+// Channel is the Postgres notification channel of stream_events.
+const Channel = "chirpy_stream"
+
+const (
+	relayBatch   = 500
+	relayPing    = 90 * time.Second
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+)
+
+// Publish stores an event with q, which may be bound to a transaction.
+// Subscribers receive it once the transaction commits.
+func Publish(ctx context.Context, q *database.Queries, topics []string, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if err := q.LockStreamEvents(ctx); err != nil {
+		return err
+	}
+
+	return q.CreateStreamEvent(ctx, database.CreateStreamEventParams{
+		Topics: topics,
+		Type:   eventType,
+		Data:   payload,
+	})
+}
+
+func NewEvent(e database.StreamEvent) Event {
+	return Event{ID: e.ID, Topics: e.Topics, Type: e.Type, Data: e.Data}
+}
+
+// Relay publishes the events that any instance stores to the local
+// broker.
+type Relay struct {
+	dbURL  string
+	db     *database.Queries
+	broker *Broker
+}
+
+func NewRelay(dbURL string, db *database.Queries, broker *Broker) *Relay {
+	return &Relay{dbURL: dbURL, db: db, broker: broker}
+}
+
+// Run relays events until ctx is cancelled. Notifications only say that
+// something new exists; the events are read from the table after the last
+// relayed id, so nothing is lost while the listener reconnects.
+func (r *Relay) Run(ctx context.Context) {
+	lastID, err := r.db.GetLatestStreamEventID(ctx)
+	if err != nil {
+		log.Printf("Could not start the stream relay: %v\n", err)
+		return
+	}
+
+	listener := pq.NewListener(r.dbURL, minReconnect, maxReconnect, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Stream listener: %v\n", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		log.Printf("Could not listen on %v: %v\n", Channel, err)
+		return
+	}
+
+	ping := time.NewTicker(relayPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-listener.Notify:
+			// A nil notification means the connection was re-established,
+			// which also calls for a catch-up read.
+			lastID = r.relay(ctx, lastID)
+		case <-ping.C:
+			go listener.Ping()
+			lastID = r.relay(ctx, lastID)
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context, lastID int64) int64 {
+	for {
+		events, err := r.db.GetStreamEventsAfter(ctx, database.GetStreamEventsAfterParams{
+			AfterID:   lastID,
+			BatchSize: relayBatch,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Could not read stream events: %v\n", err)
+			}
+			return lastID
+		}
+
+		for _, e := range events {
+			r.broker.Publish(NewEvent(e))
+			lastID = e.ID
+		}
+
+		if len(events) < relayBatch {
+			return lastID
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 3, Period: 3 * time.Second}
+
+	cases := []struct {
+		at                 time.Duration
+		expectedAllowed    bool
+		expectedRemaining  int
+		expectedRetryAfter time.Duration
+	}{
+		{at: 0, expectedAllowed: true, expectedRemaining: 2},
+		{at: 0, expectedAllowed: true, expectedRemaining: 1},
+		{at: 0, expectedAllowed: true, expectedRemaining: 0},
+		{at: 0, expectedAllowed: false, expectedRemaining: 0, expectedRetryAfter: time.Second},
+		{at: 500 * time.Millisecond, expectedAllowed: false, expectedRemaining: 0, expectedRetryAfter: 500 * time.Millisecond},
+		{at: time.Second, expectedAllowed: true, expectedRemaining: 0},
+		{at: time.Hour, expectedAllowed: true, expectedRemaining: 2},
+	}
+
+	store := NewMemoryStore()
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			store.now = func() time.Time { return start.Add(c.at) }
+
+			res, err := store.Take(ctx, "key", limit)
+			if err != nil {
+				t.Fatalf("Take returned err: %v\n", err)
+			}
+
+			if res.Allowed != c.expectedAllowed || res.Remaining != c.expectedRemaining || res.RetryAfter != c.expectedRetryAfter {
+				t.Errorf("expected allowed=%v remaining=%v retry=%v, have got: %+v\n",
+					c.expectedAllowed, c.expectedRemaining, c.expectedRetryAfter, res)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Burst: 1, Period: time.Minute}
+
+	if res, _ := store.Take(ctx, "a", limit); !res.Allowed {
+		t.Fatalf("expected first take on a to be allowed\n")
+	}
+	if res, _ := store.Take(ctx, "a", limit); res.Allowed {
+		t.Fatalf("expected second take on a to be denied\n")
+	}
+	if res, _ := store.Take(ctx, "b", limit); !res.Allowed {
+		t.Fatalf("expected first take on b to be allowed\n")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return start }
+
+	store.Take(ctx, "old", Limit{Burst: 1, Period: time.Minute})
+	store.now = func() time.Time { return start.Add(time.Hour) }
+	store.Take(ctx, "new", Limit{Burst: 1, Period: time.Minute})
+
+	if err := store.Sweep(ctx, 10*time.Minute); err != nil {
+		t.Fatalf("Sweep returned err: %v\n", err)
+	}
+
+	if _, ok := store.buckets["old"]; ok {
+		t.Errorf("expected idle bucket to be swept\n")
+	}
+	if _, ok := store.buckets["new"]; !ok {
+		t.Errorf("expected recent bucket to be kept\n")
+	}
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/paysis/chirpy/internal/database"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so that every
+// instance shares the same limits.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.rate(),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(row.LastAllowed, row.Tokens, limit), nil
+}
+
+func (s *PostgresStore) Sweep(ctx context.Context, idle time.Duration) error {
+	return s.db.DeleteIdleRateLimitBuckets(ctx, time.Now().UTC().Add(-idle))
+}
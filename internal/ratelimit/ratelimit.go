@@ -0,0 +1,64 @@
+// Package ratelimit implements token bucket rate limiting with pluggable
+// bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled evenly so that Burst more
+// are available after every Period.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// rate is the refill speed in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Result is the outcome of a single Take.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is how long until the next request is allowed. It is zero
+	// when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// Store keeps one bucket per key. Implementations must be safe for
+// concurrent use and take a token atomically.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Sweep drops buckets that have not been used for idle. A dropped
+	// bucket is full again on its next use, so this only frees memory.
+	Sweep(ctx context.Context, idle time.Duration) error
+}
+
+// newResult builds the Result for a bucket holding tokens after the take.
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	rate := limit.rate()
+	res := Result{
+		Allowed:    allowed,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: seconds((float64(limit.Burst) - tokens) / rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
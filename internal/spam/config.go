@@ -0,0 +1,110 @@
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Duration reads from JSON as a string such as "10m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Config holds the thresholds and rule settings. A chirp is held for review
+// once its total score reaches HoldScore and rejected at RejectScore.
+type Config struct {
+	HoldScore   float64 `json:"hold_score"`
+	RejectScore float64 `json:"reject_score"`
+
+	Duplicate struct {
+		Window      Duration `json:"window"`
+		MaxDistance int      `json:"max_distance"`
+		Score       float64  `json:"score"`
+	} `json:"duplicate"`
+
+	Links struct {
+		Max          int     `json:"max"`
+		ScorePerLink float64 `json:"score_per_link"`
+	} `json:"links"`
+
+	Mentions struct {
+		Max             int     `json:"max"`
+		ScorePerMention float64 `json:"score_per_mention"`
+	} `json:"mentions"`
+
+	Burst struct {
+		NewAccountAge Duration `json:"new_account_age"`
+		Window        Duration `json:"window"`
+		MaxChirps     int      `json:"max_chirps"`
+		Score         float64  `json:"score"`
+	} `json:"burst"`
+}
+
+func DefaultConfig() Config {
+	cfg := Config{
+		HoldScore:   1,
+		RejectScore: 3,
+	}
+
+	cfg.Duplicate.Window = Duration(time.Hour)
+	cfg.Duplicate.MaxDistance = 3
+	cfg.Duplicate.Score = 1
+
+	cfg.Links.Max = 2
+	cfg.Links.ScorePerLink = 0.5
+
+	cfg.Mentions.Max = 5
+	cfg.Mentions.ScorePerMention = 0.25
+
+	cfg.Burst.NewAccountAge = Duration(24 * time.Hour)
+	cfg.Burst.Window = Duration(10 * time.Minute)
+	cfg.Burst.MaxChirps = 5
+	cfg.Burst.Score = 1
+
+	return cfg
+}
+
+// LoadConfig reads a JSON file on top of DefaultConfig, so the file only
+// needs the settings it changes.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %v: %w", path, err)
+	}
+
+	if cfg.HoldScore <= 0 || cfg.RejectScore < cfg.HoldScore {
+		return cfg, fmt.Errorf("need 0 < hold_score <= reject_score")
+	}
+
+	return cfg, nil
+}
+
+// Lookback is how far back the recent chirps passed to Classify need to go.
+func (cfg Config) Lookback() time.Duration {
+	return max(time.Duration(cfg.Duplicate.Window), time.Duration(cfg.Burst.Window))
+}
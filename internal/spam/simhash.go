@@ -0,0 +1,57 @@
+package spam
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+const shingleSize = 3
+
+// Simhash fingerprints text so that near-duplicates differ in only a few
+// bits. It hashes overlapping three-word shingles of the lower-cased words.
+func Simhash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var shingles []string
+	if len(words) < shingleSize {
+		shingles = []string{strings.Join(words, " ")}
+	} else {
+		for i := 0; i+shingleSize <= len(words); i++ {
+			shingles = append(shingles, strings.Join(words[i:i+shingleSize], " "))
+		}
+	}
+
+	var weights [64]int
+	for _, shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var hash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+// Distance is the number of differing bits between two fingerprints.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
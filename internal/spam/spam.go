@@ -0,0 +1,181 @@
+// Package spam scores new chirps for spam signals and decides whether to
+// allow them, hold them for review or reject them.
+package spam
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type Outcome string
+
+const (
+	Allow  Outcome = "allow"
+	Hold   Outcome = "hold"
+	Reject Outcome = "reject"
+)
+
+// RecentChirp is an earlier chirp by the same author.
+type RecentChirp struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// Input is everything the rules look at. Recent must cover at least
+// Config.Lookback.
+type Input struct {
+	Body           string
+	AuthorJoinedAt time.Time
+	Recent         []RecentChirp
+	Now            time.Time
+}
+
+// Signal is one rule's contribution to the score. Rules that find nothing
+// return a zero Score.
+type Signal struct {
+	Rule   string  `json:"rule"`
+	Score  float64 `json:"score"`
+	Detail string  `json:"detail"`
+}
+
+type Rule interface {
+	Check(in Input) Signal
+}
+
+type Decision struct {
+	Outcome Outcome
+	Score   float64
+	Signals []Signal
+}
+
+// Classifier sums the scores of its rules. Extra rules can be appended to
+// Rules after New.
+type Classifier struct {
+	Rules  []Rule
+	config Config
+}
+
+func New(cfg Config) *Classifier {
+	return &Classifier{
+		config: cfg,
+		Rules: []Rule{
+			duplicateRule{cfg},
+			linkRule{cfg},
+			mentionRule{cfg},
+			burstRule{cfg},
+		},
+	}
+}
+
+func (c *Classifier) Config() Config {
+	return c.config
+}
+
+func (c *Classifier) Classify(in Input) Decision {
+	d := Decision{Outcome: Allow, Signals: []Signal{}}
+	for _, rule := range c.Rules {
+		s := rule.Check(in)
+		if s.Score <= 0 {
+			continue
+		}
+		d.Score += s.Score
+		d.Signals = append(d.Signals, s)
+	}
+
+	switch {
+	case d.Score >= c.config.RejectScore:
+		d.Outcome = Reject
+	case d.Score >= c.config.HoldScore:
+		d.Outcome = Hold
+	}
+	return d
+}
+
+type duplicateRule struct{ cfg Config }
+
+func (r duplicateRule) Check(in Input) Signal {
+	// Chirps without words, such as media-only ones, all share the
+	// zero fingerprint and are not compared.
+	fingerprint := Simhash(in.Body)
+	if fingerprint == 0 {
+		return Signal{Rule: "duplicate"}
+	}
+	since := in.Now.Add(-time.Duration(r.cfg.Duplicate.Window))
+
+	matches := 0
+	for _, c := range in.Recent {
+		if c.CreatedAt.Before(since) {
+			continue
+		}
+		if Distance(fingerprint, Simhash(c.Body)) <= r.cfg.Duplicate.MaxDistance {
+			matches++
+		}
+	}
+
+	if matches == 0 {
+		return Signal{Rule: "duplicate"}
+	}
+	return Signal{
+		Rule:   "duplicate",
+		Score:  r.cfg.Duplicate.Score * float64(matches),
+		Detail: fmt.Sprintf("%d near-duplicate chirps in the last %v", matches, time.Duration(r.cfg.Duplicate.Window)),
+	}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://\S+`)
+
+type linkRule struct{ cfg Config }
+
+func (r linkRule) Check(in Input) Signal {
+	links := len(linkPattern.FindAllString(in.Body, -1))
+	if links <= r.cfg.Links.Max {
+		return Signal{Rule: "links"}
+	}
+	return Signal{
+		Rule:   "links",
+		Score:  r.cfg.Links.ScorePerLink * float64(links-r.cfg.Links.Max),
+		Detail: fmt.Sprintf("%d links", links),
+	}
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@\w{3,20}\b`)
+
+type mentionRule struct{ cfg Config }
+
+func (r mentionRule) Check(in Input) Signal {
+	mentions := len(mentionPattern.FindAllString(in.Body, -1))
+	if mentions <= r.cfg.Mentions.Max {
+		return Signal{Rule: "mentions"}
+	}
+	return Signal{
+		Rule:   "mentions",
+		Score:  r.cfg.Mentions.ScorePerMention * float64(mentions-r.cfg.Mentions.Max),
+		Detail: fmt.Sprintf("%d mentions", mentions),
+	}
+}
+
+type burstRule struct{ cfg Config }
+
+func (r burstRule) Check(in Input) Signal {
+	if in.Now.Sub(in.AuthorJoinedAt) > time.Duration(r.cfg.Burst.NewAccountAge) {
+		return Signal{Rule: "burst"}
+	}
+
+	since := in.Now.Add(-time.Duration(r.cfg.Burst.Window))
+	recent := 0
+	for _, c := range in.Recent {
+		if !c.CreatedAt.Before(since) {
+			recent++
+		}
+	}
+
+	if recent < r.cfg.Burst.MaxChirps {
+		return Signal{Rule: "burst"}
+	}
+	return Signal{
+		Rule:   "burst",
+		Score:  r.cfg.Burst.Score,
+		Detail: fmt.Sprintf("new account posted %d chirps in %v", recent+1, time.Duration(r.cfg.Burst.Window)),
+	}
+}
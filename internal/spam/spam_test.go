@@ -0,0 +1,178 @@
+package spam
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimhashDistance(t *testing.T) {
+	base := "Check out my new store, huge discounts on every pair of shoes this week only"
+
+	cases := []struct {
+		other    string
+		expected bool
+	}{
+		{other: base, expected: true},
+		{other: "check out my NEW store!!! huge discounts on every pair of shoes this week only", expected: true},
+		{other: "Check out my new store, huge discounts on every pair of shoes this week only!!", expected: true},
+		{other: "Had a lovely walk along the river this morning, the weather was perfect", expected: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			distance := Distance(Simhash(base), Simhash(c.other))
+			if near := distance <= 3; near != c.expected {
+				t.Errorf("expected near-duplicate=%v, have got distance: %v\n", c.expected, distance)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-365 * 24 * time.Hour)
+	spammy := "Huge discounts on shoes at my store this week only, do not miss out"
+
+	burst := make([]RecentChirp, 5)
+	for i := range burst {
+		burst[i] = RecentChirp{Body: fmt.Sprintf("chirp number %d about something else", i), CreatedAt: now.Add(-time.Minute)}
+	}
+
+	cases := []struct {
+		input           Input
+		expectedOutcome Outcome
+		expectedRules   []string
+	}{
+		{
+			input:           Input{Body: "Just a normal chirp", AuthorJoinedAt: old},
+			expectedOutcome: Allow,
+		},
+		{
+			input: Input{Body: spammy, AuthorJoinedAt: old, Recent: []RecentChirp{
+				{Body: spammy, CreatedAt: now.Add(-10 * time.Minute)},
+			}},
+			expectedOutcome: Hold,
+			expectedRules:   []string{"duplicate"},
+		},
+		{
+			input: Input{Body: spammy, AuthorJoinedAt: old, Recent: []RecentChirp{
+				{Body: spammy, CreatedAt: now.Add(-2 * time.Hour)},
+			}},
+			expectedOutcome: Allow,
+		},
+		{
+			input: Input{Body: spammy, AuthorJoinedAt: old, Recent: []RecentChirp{
+				{Body: spammy, CreatedAt: now.Add(-time.Minute)},
+				{Body: spammy, CreatedAt: now.Add(-2 * time.Minute)},
+				{Body: spammy, CreatedAt: now.Add(-3 * time.Minute)},
+			}},
+			expectedOutcome: Reject,
+			expectedRules:   []string{"duplicate"},
+		},
+		{
+			input:           Input{Body: "", AuthorJoinedAt: old, Recent: []RecentChirp{{Body: "", CreatedAt: now}}},
+			expectedOutcome: Allow,
+		},
+		{
+			input:           Input{Body: "http://a.example http://b.example", AuthorJoinedAt: old},
+			expectedOutcome: Allow,
+		},
+		{
+			input:           Input{Body: strings.Repeat("https://x.example ", 4), AuthorJoinedAt: old},
+			expectedOutcome: Hold,
+			expectedRules:   []string{"links"},
+		},
+		{
+			input:           Input{Body: "@alice @bob @carol @dave @erin @frank @grace @heidi @ivan", AuthorJoinedAt: old},
+			expectedOutcome: Hold,
+			expectedRules:   []string{"mentions"},
+		},
+		{
+			input:           Input{Body: "mail me at someone@example.com", AuthorJoinedAt: old},
+			expectedOutcome: Allow,
+		},
+		{
+			input:           Input{Body: "another one", AuthorJoinedAt: now.Add(-time.Hour), Recent: burst},
+			expectedOutcome: Hold,
+			expectedRules:   []string{"burst"},
+		},
+		{
+			input:           Input{Body: "another one", AuthorJoinedAt: old, Recent: burst},
+			expectedOutcome: Allow,
+		},
+	}
+
+	classifier := New(DefaultConfig())
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			c.input.Now = now
+			d := classifier.Classify(c.input)
+
+			rules := []string{}
+			for _, s := range d.Signals {
+				rules = append(rules, s.Rule)
+			}
+
+			if d.Outcome != c.expectedOutcome || strings.Join(rules, ",") != strings.Join(c.expectedRules, ",") {
+				t.Errorf("expected %v from %v, have got: %v from %v (score %v)\n",
+					c.expectedOutcome, c.expectedRules, d.Outcome, rules, d.Score)
+			}
+		})
+	}
+}
+
+type constRule float64
+
+func (r constRule) Check(in Input) Signal {
+	return Signal{Rule: "const", Score: float64(r)}
+}
+
+func TestClassifyExtraRule(t *testing.T) {
+	classifier := New(DefaultConfig())
+	classifier.Rules = append(classifier.Rules, constRule(5))
+
+	d := classifier.Classify(Input{Body: "hello", Now: time.Now()})
+	if d.Outcome != Reject {
+		t.Errorf("expected %v, have got: %v\n", Reject, d.Outcome)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	cases := []struct {
+		contents      string
+		expectedHold  float64
+		expectedLinks int
+		expectedErr   bool
+	}{
+		{contents: `{}`, expectedHold: 1, expectedLinks: 2},
+		{contents: `{"hold_score": 2, "links": {"max": 4}}`, expectedHold: 2, expectedLinks: 4},
+		{contents: `{"duplicate": {"window": "soon"}}`, expectedErr: true},
+		{contents: `{"hold_score": 5, "reject_score": 4}`, expectedErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spam.json")
+			if err := os.WriteFile(path, []byte(c.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadConfig(path)
+			if c.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, have got: %+v\n", cfg)
+				}
+				return
+			}
+
+			if err != nil || cfg.HoldScore != c.expectedHold || cfg.Links.Max != c.expectedLinks {
+				t.Errorf("expected hold=%v links=%v, have got: %v %v (err %v)\n",
+					c.expectedHold, c.expectedLinks, cfg.HoldScore, cfg.Links.Max, err)
+			}
+		})
+	}
+}
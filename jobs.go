@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/jobs"
+)
+
+const (
+	jobChirpEvents         = "chirp.events"
+	jobPublishScheduled    = "chirps.publish"
+	jobDeliverWebhooks     = "webhooks.deliver"
+	jobProcessWebhookEvent = "webhooks.process"
+	jobExpireSubscriptions = "subscriptions.expire"
+	jobPurge               = "purge"
+	jobCleanupJobs         = "jobs.cleanup"
+	jobCleanupStream       = "stream.cleanup"
+)
+
+// finishedJobRetention is how long done jobs stay visible in the stats.
+const finishedJobRetention = 7 * 24 * time.Hour
+
+type chirpJob struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (cfg *apiConfig) registerJobs() {
+	cfg.jobs.Register(jobChirpEvents, 4, cfg.runChirpEventsJob)
+	cfg.jobs.Register(jobPublishScheduled, 1, cfg.runPublishScheduledJob)
+	cfg.jobs.Register(jobDeliverWebhooks, 1, cfg.runDeliverWebhooksJob)
+	cfg.jobs.Register(jobProcessWebhookEvent, 4, cfg.runProcessWebhookEventJob)
+	cfg.jobs.Register(jobExpireSubscriptions, 1, cfg.runExpireSubscriptionsJob)
+	cfg.jobs.Register(jobPurge, 1, cfg.runPurgeJob)
+	cfg.jobs.Register(jobCleanupJobs, 1, cfg.runCleanupJobsJob)
+	cfg.jobs.Register(jobCleanupStream, 1, cfg.runCleanupStreamJob)
+
+	cfg.jobs.Schedule(jobPublishScheduled, 15*time.Second)
+	cfg.jobs.Schedule(jobDeliverWebhooks, 5*time.Second)
+	cfg.jobs.Schedule(jobExpireSubscriptions, time.Minute)
+	cfg.jobs.Schedule(jobPurge, time.Hour)
+	cfg.jobs.Schedule(jobCleanupJobs, 24*time.Hour)
+	cfg.jobs.Schedule(jobCleanupStream, time.Hour)
+}
+
+// enqueueChirpEventsJob fans out the webhook events of a chirp in the
+// background. Pass the queries of the transaction that published it.
+func enqueueChirpEventsJob(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.Status != chirpStatusPublished {
+		return nil
+	}
+
+	_, err := jobs.Enqueue(ctx, q, jobs.NewJob{
+		Type:    jobChirpEvents,
+		Payload: chirpJob{ChirpID: chirp.ID},
+	})
+	return err
+}
+
+func (cfg *apiConfig) runChirpEventsJob(ctx context.Context, payload json.RawMessage) error {
+	var job chirpJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.GetChirp(ctx, job.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted before the job ran.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := enqueueChirpEvents(ctx, qtx, chirp); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) runPublishScheduledJob(ctx context.Context, _ json.RawMessage) error {
+	return cfg.publishDueChirps(ctx)
+}
+
+func (cfg *apiConfig) runDeliverWebhooksJob(ctx context.Context, _ json.RawMessage) error {
+	return cfg.deliverWebhooks(ctx)
+}
+
+func (cfg *apiConfig) runExpireSubscriptionsJob(ctx context.Context, _ json.RawMessage) error {
+	return cfg.expireSubscriptions(ctx)
+}
+
+// runPurgeJob permanently removes chirps that were deleted longer than
+// cfg.retention ago, along with their attachments, and cleans up uploads
+// that were never attached to a chirp.
+func (cfg *apiConfig) runPurgeJob(ctx context.Context, _ json.RawMessage) error {
+	cfg.purgeDeletedChirps(ctx)
+	cfg.purgeOrphanMedia(ctx)
+	return nil
+}
+
+func (cfg *apiConfig) runCleanupJobsJob(ctx context.Context, _ json.RawMessage) error {
+	deleted, err := cfg.db.DeleteFinishedJobs(ctx, sql.NullTime{
+		Time:  time.Now().UTC().Add(-finishedJobRetention),
+		Valid: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Printf("Deleted %d finished jobs\n", deleted)
+	}
+	return nil
+}
+
+type jobStatsVal struct {
+	Type    string `json:"type"`
+	Pending int64  `json:"pending"`
+	Running int64  `json:"running"`
+	Done    int64  `json:"done"`
+	Failed  int64  `json:"failed"`
+	// OldestPendingAt is when the longest-waiting pending job became due.
+	OldestPendingAt *time.Time `json:"oldest_pending_at"`
+}
+
+func (cfg *apiConfig) HandleJobStats(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	rows, err := cfg.db.GetJobStats(r.Context())
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := []jobStatsVal{}
+	for _, row := range rows {
+		if len(retVals) == 0 || retVals[len(retVals)-1].Type != row.Type {
+			retVals = append(retVals, jobStatsVal{Type: row.Type})
+		}
+		stats := &retVals[len(retVals)-1]
+
+		switch row.Status {
+		case "pending":
+			stats.Pending = row.Count
+			oldest := row.OldestRunAt
+			stats.OldestPendingAt = &oldest
+		case "running":
+			stats.Running = row.Count
+		case "done":
+			stats.Done = row.Count
+		case "failed":
+			stats.Failed = row.Count
+		}
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
@@ -2,22 +2,39 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/paysis/chirpy/internal/audit"
 	"github.com/paysis/chirpy/internal/auth"
+	"github.com/paysis/chirpy/internal/blobstore"
+	"github.com/paysis/chirpy/internal/chirptext"
 	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/delivery"
+	"github.com/paysis/chirpy/internal/encryption"
+	"github.com/paysis/chirpy/internal/entitlements"
+	"github.com/paysis/chirpy/internal/jobs"
+	"github.com/paysis/chirpy/internal/profanity"
+	"github.com/paysis/chirpy/internal/pubsub"
+	"github.com/paysis/chirpy/internal/ratelimit"
+	"github.com/paysis/chirpy/internal/spam"
 )
 
 func main() {
@@ -35,19 +52,87 @@ func main() {
 
 	smux.HandleFunc("GET /admin/metrics", apiCfg.HandleMetrics)
 	smux.HandleFunc("POST /admin/reset", apiCfg.HandleReset)
-
-	smux.HandleFunc("POST /api/users", apiCfg.HandleCreateUser)
-	smux.HandleFunc("POST /api/login", apiCfg.HandleLogin)
-	smux.HandleFunc("POST /api/chirps", apiCfg.HandleCreateChirp)
-	smux.HandleFunc("GET /api/chirps", apiCfg.HandleGetAllChirps)
-	smux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.HandleGetChirp)
-	smux.HandleFunc("POST /api/refresh", apiCfg.HandleRefreshToken)
+	smux.HandleFunc("GET /admin/profanity", apiCfg.HandleListProfaneTerms)
+	smux.HandleFunc("POST /admin/profanity", apiCfg.HandleCreateProfaneTerm)
+	smux.HandleFunc("PUT /admin/profanity/{termID}", apiCfg.HandleUpdateProfaneTerm)
+	smux.HandleFunc("DELETE /admin/profanity/{termID}", apiCfg.HandleDeleteProfaneTerm)
+	smux.HandleFunc("GET /admin/jobs", apiCfg.HandleJobStats)
+	smux.HandleFunc("GET /admin/audit", apiCfg.HandleListAuditEvents)
+	smux.HandleFunc("GET /admin/audit/verify", apiCfg.HandleVerifyAuditLog)
+	smux.HandleFunc("GET /admin/webhooks/events", apiCfg.HandleListWebhookEvents)
+	smux.HandleFunc("GET /admin/webhooks/events/{eventID}", apiCfg.HandleGetWebhookEvent)
+	smux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.HandleReplayWebhookEvent)
+	smux.HandleFunc("GET /admin/users/{userID}/entitlements", apiCfg.HandleGetUserEntitlements)
+	smux.HandleFunc("PUT /admin/users/{userID}/entitlements/{capability}", apiCfg.HandleGrantEntitlement)
+	smux.HandleFunc("DELETE /admin/users/{userID}/entitlements/{capability}", apiCfg.HandleRevokeEntitlement)
+
+	smux.HandleFunc("POST /api/users", apiCfg.rateLimit(rateLimitSignup, apiCfg.HandleCreateUser))
+	smux.HandleFunc("POST /api/login", apiCfg.rateLimit(rateLimitAuth, apiCfg.HandleLogin))
+	smux.HandleFunc("POST /api/chirps", apiCfg.rateLimit(rateLimitPost, apiCfg.HandleCreateChirp))
+	smux.HandleFunc("GET /api/chirps", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetAllChirps))
+	smux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetChirp))
+	smux.HandleFunc("POST /api/refresh", apiCfg.rateLimit(rateLimitAuth, apiCfg.HandleRefreshToken))
 	smux.HandleFunc("POST /api/revoke", apiCfg.HandleRevoke)
-	smux.HandleFunc("PUT /api/users", apiCfg.HandleUpdateUser)
-	smux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.HandleDeleteChirp)
+	smux.HandleFunc("PUT /api/users", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleUpdateUser))
+	smux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleDeleteChirp))
+	smux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleRestoreChirp))
+	smux.HandleFunc("POST /api/chirps/{chirpID}/publish", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandlePublishChirp))
+	smux.HandleFunc("POST /api/chirps/{chirpID}/poll/vote", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleVotePoll))
+	smux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleReportChirp))
+
+	smux.HandleFunc("POST /api/media", apiCfg.rateLimit(rateLimitUpload, apiCfg.HandleUploadMedia))
+	smux.HandleFunc("GET /api/media/{mediaID}", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetMedia))
+	smux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetMediaThumbnail))
+
+	smux.HandleFunc("GET /api/users/{handle}", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetProfile))
+	smux.HandleFunc("GET /api/users/me", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetMe))
+	smux.HandleFunc("PATCH /api/users/me", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleUpdateProfile))
+	smux.HandleFunc("GET /api/users/me/preferences", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetPreferences))
+	smux.HandleFunc("PATCH /api/users/me/preferences", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleUpdatePreferences))
+
+	smux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleFollowUser))
+	smux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleUnfollowUser))
+	smux.HandleFunc("POST /api/users/{userID}/block", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleBlockUser))
+	smux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleUnblockUser))
+	smux.HandleFunc("GET /api/blocks", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetBlocks))
+	smux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleMuteUser))
+	smux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleUnmuteUser))
+	smux.HandleFunc("GET /api/mutes", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleGetMutes))
+	smux.HandleFunc("POST /api/users/{userID}/report", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleReportUser))
+
+	smux.HandleFunc("GET /api/moderation/reports", apiCfg.HandleListReports)
+	smux.HandleFunc("POST /api/moderation/reports/{reportID}/claim", apiCfg.HandleClaimReport)
+	smux.HandleFunc("POST /api/moderation/reports/{reportID}/resolve", apiCfg.HandleResolveReport)
+	smux.HandleFunc("GET /api/moderation/log", apiCfg.HandleListModerationLog)
+	smux.HandleFunc("GET /api/moderation/spam", apiCfg.HandleListSpamDecisions)
+	smux.HandleFunc("PUT /api/moderation/chirps/{chirpID}/sensitivity", apiCfg.HandleSetChirpSensitivity)
+	smux.HandleFunc("PUT /api/moderation/users/{userID}/suspension", apiCfg.HandleSuspendUser)
+	smux.HandleFunc("DELETE /api/moderation/users/{userID}/suspension", apiCfg.HandleUnsuspendUser)
+	smux.HandleFunc("PUT /api/moderation/users/{userID}/shadow-ban", apiCfg.HandleShadowBanUser)
+	smux.HandleFunc("DELETE /api/moderation/users/{userID}/shadow-ban", apiCfg.HandleUnshadowBanUser)
 
 	smux.HandleFunc("POST /api/polka/webhooks", apiCfg.HandlePolkaWebhook)
 
+	smux.HandleFunc("GET /api/stream", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleStream))
+	smux.HandleFunc("GET /api/ws", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleWebSocket))
+
+	smux.HandleFunc("GET /api/notifications", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleListNotifications))
+	smux.HandleFunc("GET /api/notifications/unread-count", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleUnreadNotificationCount))
+	smux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleMarkNotificationRead))
+	smux.HandleFunc("POST /api/notifications/read-all", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleMarkAllNotificationsRead))
+
+	smux.HandleFunc("POST /api/conversations", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleCreateConversation))
+	smux.HandleFunc("GET /api/conversations", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleListConversations))
+	smux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleListMessages))
+	smux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.rateLimit(rateLimitMessage, apiCfg.HandleSendMessage))
+	smux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleMarkConversationRead))
+
+	smux.HandleFunc("POST /api/webhooks", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleCreateWebhookEndpoint))
+	smux.HandleFunc("GET /api/webhooks", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleListWebhookEndpoints))
+	smux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleDeleteWebhookEndpoint))
+	smux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.rateLimit(rateLimitRead, apiCfg.HandleListWebhookDeliveries))
+	smux.HandleFunc("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/retry", apiCfg.rateLimit(rateLimitWrite, apiCfg.HandleRetryWebhookDelivery))
+
 	smux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(200)
@@ -58,17 +143,99 @@ func main() {
 		Handler: smux,
 		Addr:    ":" + port,
 	}
+	// Ends open streams, which Shutdown would otherwise wait for, and
+	// WebSockets, which it does not track.
+	server.RegisterOnShutdown(apiCfg.stream.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workers := sync.WaitGroup{}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		apiCfg.jobs.Run(ctx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		apiCfg.streamRelay.Run(ctx)
+	}()
+
+	// Work shared between instances runs as jobs. These loops maintain
+	// state kept in each instance's memory, so every instance runs them.
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		runEvery(ctx, 10*time.Minute, apiCfg.sweepRateLimits)
+	}()
+
+	apiCfg.reloadProfanity(ctx)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		runEvery(ctx, time.Minute, apiCfg.reloadProfanity)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Could not shut down cleanly: %v\n", err)
+		}
+	}()
 
 	log.Printf("Running on port: %s\n", port)
-	log.Fatal(server.ListenAndServe())
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+
+	workers.Wait()
+}
+
+// runEvery calls fn right away and then on every tick until ctx is
+// cancelled. It is the loop behind the in-process background workers.
+func runEvery(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
+	blobs          blobstore.BlobStore
+	audit          *audit.Log
+	profanity      *profanity.Filter
+	rateLimits     ratelimit.Store
+	rateTiers      rateTierCache
+	spam           *spam.Classifier
+	webhooks       *delivery.Sender
+	jobs           *jobs.Runner
+	messageKeys    encryption.KeyProvider
+	stream         *pubsub.Broker
+	streamRelay    *pubsub.Relay
+	trustProxy     bool
 	platform       string
 	jwtSecret      string
-	polkaSecret    string
+	polka          polkaConfig
+	undoWindow     time.Duration
+	retention      time.Duration
+	redGracePeriod time.Duration
+
+	reportHideThreshold int32
 }
 
 func NewApiConfig(hitVal int32) *apiConfig {
@@ -80,19 +247,123 @@ func NewApiConfig(hitVal int32) *apiConfig {
 	}
 
 	jwtSecret := os.Getenv("JWT_SECRET")
-	polkaSecret := os.Getenv("POLKA_KEY")
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+
+	blobs, err := blobstore.NewLocalStore(mediaDir)
+	if err != nil {
+		log.Panicf("Could not open media directory %v: %v\n", mediaDir, err)
+	}
+
+	undoWindow := durationFromEnv("CHIRP_UNDO_WINDOW", 10*time.Minute)
+	retention := durationFromEnv("CHIRP_RETENTION", 30*24*time.Hour)
+	if retention < undoWindow {
+		log.Panicln("CHIRP_RETENTION must not be shorter than CHIRP_UNDO_WINDOW")
+	}
+
+	var rateLimits ratelimit.Store
+	switch store := os.Getenv("RATE_LIMIT_STORE"); store {
+	case "", "memory":
+		rateLimits = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimits = ratelimit.NewPostgresStore(database.New(db))
+	default:
+		log.Panicf("Unknown RATE_LIMIT_STORE %q\n", store)
+	}
+
+	classifier := spam.New(spam.DefaultConfig())
+	if path := os.Getenv("SPAM_CONFIG"); path != "" {
+		spamConfig, err := spam.LoadConfig(path)
+		if err != nil {
+			log.Panicf("Could not load spam config: %v\n", err)
+		}
+		classifier = spam.New(spamConfig)
+	}
+
+	reportHideThreshold := intFromEnv("REPORT_HIDE_THRESHOLD", 5)
+	if reportHideThreshold < 1 {
+		log.Panicln("REPORT_HIDE_THRESHOLD must be at least 1")
+	}
+
+	// Direct messages stay off until there is a key to encrypt them with.
+	// The first key seals new messages; the others open older ones.
+	var messageKeys encryption.KeyProvider
+	if raw := os.Getenv("DM_ENCRYPTION_KEYS"); raw != "" {
+		keys, err := encryption.ParseKeys(raw)
+		if err != nil {
+			log.Panicf("Could not parse DM_ENCRYPTION_KEYS: %v\n", err)
+		}
+		staticKeys, err := encryption.NewStaticKeys(keys)
+		if err != nil {
+			log.Panicf("Could not load DM_ENCRYPTION_KEYS: %v\n", err)
+		}
+		messageKeys = staticKeys
+	}
+
+	jobsConfig := jobs.DefaultConfig()
+	jobsConfig.DrainTimeout = durationFromEnv("JOB_DRAIN_TIMEOUT", jobsConfig.DrainTimeout)
+
+	broker := pubsub.NewBroker()
 
 	cfg := &apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             database.New(db),
+		dbConn:         db,
+		blobs:          blobs,
+		audit:          audit.New(db),
+		profanity:      profanity.New(nil),
+		rateLimits:     rateLimits,
+		spam:           classifier,
+		webhooks:       delivery.NewSender(delivery.SafeClient(deliveryTimeout)),
+		jobs:           jobs.NewRunner(jobs.NewPostgresStore(database.New(db)), jobsConfig),
+		messageKeys:    messageKeys,
+		stream:         broker,
+		streamRelay:    pubsub.NewRelay(dbURL, database.New(db), broker),
+		trustProxy:     os.Getenv("TRUST_PROXY") == "true",
 		platform:       os.Getenv("PLATFORM"),
 		jwtSecret:      jwtSecret,
-		polkaSecret:    polkaSecret,
+		polka:          polkaConfigFromEnv(),
+		undoWindow:     undoWindow,
+		retention:      retention,
+		redGracePeriod: durationFromEnv("RED_GRACE_PERIOD", 3*24*time.Hour),
+
+		reportHideThreshold: int32(reportHideThreshold),
 	}
+	cfg.registerJobs()
 	cfg.fileserverHits.Store(hitVal)
 	return cfg
 }
 
+// durationFromEnv parses an optional duration such as "10m" or "720h".
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		log.Panicf("Could not parse %v: %q\n", key, raw)
+	}
+	return d
+}
+
+func intFromEnv(key string, fallback int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Panicf("Could not parse %v: %q\n", key, raw)
+	}
+	return n
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -131,6 +402,7 @@ func (cfg *apiConfig) HandleReset(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		log.Printf("Could not delete all users: %v\n", err)
 	}
+	cfg.recordAudit(req, audit.Event{Kind: audit.AdminReset})
 
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(200)
@@ -150,6 +422,8 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed  bool      `json:"is_chirpy_red"`
 		Token        string    `json:"token"`
 		RefreshToken string    `json:"refresh_token"`
+
+		Entitlements entitlementsVal `json:"entitlements"`
 	}
 
 	params := parameters{}
@@ -163,6 +437,12 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 
 	dbUser, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 
+	if errors.Is(err, sql.ErrNoRows) {
+		cfg.recordAudit(r, audit.Event{
+			Kind:     audit.LoginFailed,
+			Metadata: map[string]string{"reason": "unknown_email", "email": params.Email},
+		})
+	}
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong with db")
 		return
@@ -171,12 +451,26 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	err = auth.CheckPasswordHash(params.Password, dbUser.HashedPassword)
 
 	if err != nil {
+		cfg.recordAudit(r, audit.Event{
+			Kind:     audit.LoginFailed,
+			TargetID: dbUser.ID,
+			Metadata: map[string]string{"reason": "password"},
+		})
 		respondWithError(w, 401, "Incorrect email or password")
 		return
 	}
 
-	token, err := auth.MakeJWT(dbUser.ID, cfg.jwtSecret)
+	if isSuspended(dbUser.SuspendedUntil, time.Now().UTC()) {
+		cfg.recordAudit(r, audit.Event{
+			Kind:     audit.LoginFailed,
+			TargetID: dbUser.ID,
+			Metadata: map[string]string{"reason": "suspended"},
+		})
+		respondWithError(w, 403, "Account is suspended")
+		return
+	}
 
+	ents, err := cfg.userEntitlements(r.Context(), dbUser)
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong")
 		return
@@ -189,6 +483,13 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	token, err := auth.MakeSessionJWT(dbUser.ID, auth.SessionID(refreshToken), cfg.jwtSecret)
+
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
 	dbToken := database.CreateRefreshTokenParams{
 		Token:     refreshToken,
 		UserID:    dbUser.ID,
@@ -202,6 +503,8 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg.recordAudit(r, audit.Event{Kind: audit.LoginSucceeded, ActorID: dbUser.ID, TargetID: dbUser.ID})
+
 	retVal := returnVal{
 		ID:           dbUser.ID,
 		CreatedAt:    dbUser.CreatedAt,
@@ -210,6 +513,8 @@ func (cfg *apiConfig) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed:  dbUser.IsChirpyRed,
 		Token:        token,
 		RefreshToken: refreshToken,
+
+		Entitlements: newEntitlementsVal(ents),
 	}
 
 	respondWithJSON(w, 200, retVal)
@@ -240,7 +545,12 @@ func (cfg *apiConfig) HandleRefreshToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	jwtToken, err := auth.MakeJWT(row.UserID, cfg.jwtSecret)
+	if isSuspended(row.SuspendedUntil, time.Now().UTC()) {
+		respondWithError(w, 403, "Account is suspended")
+		return
+	}
+
+	jwtToken, err := auth.MakeSessionJWT(row.UserID, auth.SessionID(refreshToken), cfg.jwtSecret)
 
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong")
@@ -271,15 +581,8 @@ func (cfg *apiConfig) HandleDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	jwtToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
-		return
-	}
-
-	userId, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -295,7 +598,7 @@ func (cfg *apiConfig) HandleDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), chirp.ID)
+	err = cfg.db.SoftDeleteChirp(r.Context(), chirp.ID)
 
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong")
@@ -305,50 +608,6 @@ func (cfg *apiConfig) HandleDeleteChirp(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(204)
 }
 
-func (cfg *apiConfig) HandlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
-
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
-		return
-	}
-
-	if apiKey != cfg.polkaSecret {
-		respondWithError(w, 401, "Unauthorized")
-		return
-	}
-
-	params := parameters{}
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&params); err != nil {
-		respondWithError(w, 400, "Bad request")
-		return
-	}
-
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(204) // we do not care about other events
-		return
-	}
-
-	dbParams := database.UpdateUserRedParams{
-		IsChirpyRed: true,
-		ID:          params.Data.UserID,
-	}
-	err = cfg.db.UpdateUserRed(r.Context(), dbParams)
-	if err != nil {
-		respondWithError(w, 404, "Not found")
-		return
-	}
-
-	w.WriteHeader(204)
-}
-
 func (cfg *apiConfig) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
@@ -363,17 +622,8 @@ func (cfg *apiConfig) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed bool      `json:"is_chirpy_red"`
 	}
 
-	jwtToken, err := auth.GetBearerToken(r.Header)
-
-	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
-		return
-	}
-
-	userId, err := auth.ValidateJWT(jwtToken, cfg.jwtSecret)
-
-	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
 		return
 	}
 
@@ -407,6 +657,18 @@ func (cfg *apiConfig) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if dbUser.Email != user.Email {
+		cfg.recordAudit(r, audit.Event{
+			Kind:     audit.EmailChanged,
+			ActorID:  user.ID,
+			TargetID: user.ID,
+			Metadata: map[string]string{"old_email": user.Email, "new_email": dbUser.Email},
+		})
+	}
+	if auth.CheckPasswordHash(params.Password, user.HashedPassword) != nil {
+		cfg.recordAudit(r, audit.Event{Kind: audit.PasswordChanged, ActorID: user.ID, TargetID: user.ID})
+	}
+
 	retval := returnVal{
 		ID:          dbUser.ID,
 		CreatedAt:   dbUser.CreatedAt,
@@ -426,13 +688,48 @@ func (cfg *apiConfig) HandleRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(r.Context(), refreshToken)
+	row, err := cfg.db.GetUserFromRefreshToken(r.Context(), refreshToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.RevokeRefreshToken(r.Context(), refreshToken)
 
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong")
 		return
 	}
 
+	// Revoking again is harmless and makes sure live connections of the
+	// session are closed.
+	err = publishSessionRevoked(r.Context(), qtx, row.UserID, auth.SessionID(refreshToken))
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if !row.RevokedAt.Valid {
+		cfg.recordAudit(r, audit.Event{Kind: audit.TokenRevoked, ActorID: row.UserID, TargetID: row.UserID})
+	}
+
 	w.WriteHeader(204)
 }
 
@@ -440,6 +737,7 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	type returnVal struct {
@@ -447,6 +745,7 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 		CreatedAt   time.Time `json:"created_at"`
 		UpdatedAt   time.Time `json:"updated_at"`
 		Email       string    `json:"email"`
+		Handle      string    `json:"handle"`
 		IsChirpyRed bool      `json:"is_chirpy_red"`
 	}
 
@@ -458,6 +757,17 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if params.Handle == "" {
+		params.Handle, err = generateHandle()
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	} else if err := validateHandle(params.Handle); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 
 	if err != nil {
@@ -468,19 +778,27 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 	dbUser := database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Handle:         params.Handle,
 	}
 
 	user, err := cfg.db.CreateUser(r.Context(), dbUser)
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Email or handle is already taken")
+		return
+	}
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong")
 		return
 	}
 
+	cfg.recordAudit(r, audit.Event{Kind: audit.UserCreated, ActorID: user.ID, TargetID: user.ID})
+
 	retVal := returnVal{
 		ID:          user.ID,
 		CreatedAt:   user.CreatedAt,
 		UpdatedAt:   user.UpdatedAt,
 		Email:       user.Email,
+		Handle:      user.Handle,
 		IsChirpyRed: user.IsChirpyRed,
 	}
 
@@ -488,14 +806,6 @@ func (cfg *apiConfig) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) HandleGetChirp(w http.ResponseWriter, r *http.Request) {
-	type returnVal struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"`
-	}
-
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 
 	if err != nil {
@@ -504,33 +814,39 @@ func (cfg *apiConfig) HandleGetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	viewerID, err := cfg.viewerFromRequest(r)
+
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	chirp, err := cfg.getVisibleChirp(r.Context(), viewerID, chirpID)
+
+	if errors.Is(err, errChirpDeleted) {
+		respondWithJSON(w, 410, tombstoneVal{
+			ID:        chirp.ID,
+			DeletedAt: chirp.DeletedAt.Time,
+		})
+		return
+	}
 
 	if err != nil {
 		respondWithError(w, 404, "Not found")
 		return
 	}
 
-	retVal := returnVal{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	retVals, err := cfg.chirpVals(r.Context(), viewerID, []database.Chirp{chirp}, wantsExpand(r, "author"))
+
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
 	}
 
-	respondWithJSON(w, 200, retVal)
+	respondWithJSON(w, 200, retVals[0])
 }
 
 func (cfg *apiConfig) HandleGetAllChirps(w http.ResponseWriter, r *http.Request) {
-	type returnVal struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"`
-	}
-
 	authorId := r.URL.Query().Get("author_id")
 	authorUUID, err := uuid.Parse(authorId)
 
@@ -545,12 +861,22 @@ func (cfg *apiConfig) HandleGetAllChirps(w http.ResponseWriter, r *http.Request)
 		authorId = ""
 	}
 
+	viewerID, err := cfg.viewerFromRequest(r)
+
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
 	var chirps []database.Chirp
 
 	if authorId == "" {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
+		chirps, err = cfg.db.GetAllChirps(r.Context(), viewerID)
 	} else {
-		chirps, err = cfg.db.GetChirpsByUserId(r.Context(), authorUUID)
+		chirps, err = cfg.db.GetChirpsByUserId(r.Context(), database.GetChirpsByUserIdParams{
+			UserID:   authorUUID,
+			ViewerID: viewerID,
+		})
 	}
 
 	if err != nil {
@@ -570,15 +896,11 @@ func (cfg *apiConfig) HandleGetAllChirps(w http.ResponseWriter, r *http.Request)
 		})
 	}
 
-	retVals := make([]returnVal, 0, len(chirps))
-	for _, chirp := range chirps {
-		retVals = append(retVals, returnVal{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+	retVals, err := cfg.chirpVals(r.Context(), viewerID, chirps, wantsExpand(r, "author"))
+
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
 	}
 
 	respondWithJSON(w, 200, retVals)
@@ -586,57 +908,130 @@ func (cfg *apiConfig) HandleGetAllChirps(w http.ResponseWriter, r *http.Request)
 
 func (cfg *apiConfig) HandleCreateChirp(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body       string      `json:"body"`
+		MediaIDs   []uuid.UUID `json:"media_ids"`
+		Status     string      `json:"status"`
+		PublishAt  *time.Time  `json:"publish_at"`
+		Poll       *pollParams `json:"poll"`
+		Visibility string      `json:"visibility"`
+
+		ContentWarning string `json:"content_warning"`
+		Sensitive      bool   `json:"sensitive"`
 	}
 
-	type returnVal struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"`
+	userId, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
 
+	user, err := cfg.db.GetUserById(r.Context(), userId)
 	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
+		respondWithError(w, 500, "Something went wrong")
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	params.Body = chirptext.Normalize(params.Body)
+	if params.Body == "" && len(params.MediaIDs) == 0 && params.Poll == nil {
+		respondWithError(w, 400, "Chirp cannot be empty")
+		return
+	}
 
+	ents, err := cfg.userEntitlements(r.Context(), user)
 	if err != nil {
-		respondWithError(w, 401, "Unauthorized")
+		respondWithError(w, 500, "Something went wrong")
 		return
 	}
 
-	params := parameters{}
-	decoder := json.NewDecoder(r.Body)
+	if limit := ents.ChirpLength(); chirptext.Length(params.Body) > limit {
+		respondWithError(w, 400, fmt.Sprintf("Chirp is too long, the limit is %d characters", limit))
+		return
+	}
 
-	err = decoder.Decode(&params)
+	status, publishAt, err := resolveChirpStatus(params.Status, params.PublishAt, time.Now().UTC())
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	visibility, err := validateVisibility(params.Visibility)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	if limit := ents.MediaPerChirp(); len(params.MediaIDs) > limit {
+		respondWithError(w, 400, fmt.Sprintf("A chirp can have at most %d attachments", limit))
+		return
+	}
+
+	var pollLabels []string
+	if params.Poll != nil {
+		if !ents.Has(entitlements.Polls) {
+			respondWithError(w, 403, "Polls are a Chirpy Red feature")
+			return
+		}
+
+		pollLabels, err = validatePoll(*params.Poll, time.Now().UTC())
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+	}
+
+	filtered := cfg.profanity.Check(params.Body)
+	if filtered.Rejected {
+		respondWithError(w, 400, "Chirp contains language that is not allowed")
+		return
+	}
+	params.Body = filtered.Text
+
+	params.ContentWarning, err = cfg.validateContentWarning(params.ContentWarning)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	verdict, err := cfg.classifySpam(r.Context(), user, params.Body, time.Now().UTC())
 	if err != nil {
 		respondWithError(w, 500, "Something went wrong")
 		return
 	}
 
-	if len(params.Body) > 140 {
-		respondWithError(w, 400, "Chirp is too long")
+	if verdict.Outcome == spam.Reject {
+		if err := logSpamDecision(r.Context(), cfg.db, userId, uuid.NullUUID{}, verdict); err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+		respondWithError(w, 400, "Chirp was rejected as spam")
 		return
 	}
 
-	// profane
-	profaneList := []string{
-		"kerfuffle",
-		"sharbert",
-		"fornax",
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	params.Body = filterProfaneWords(params.Body, profaneList)
+	chirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:       params.Body,
+		UserID:     userId,
+		Status:     status,
+		PublishAt:  publishAt,
+		Visibility: visibility,
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: userId,
+		ContentWarning: params.ContentWarning,
+		Sensitive:      params.Sensitive,
 	})
 
 	if err != nil {
@@ -644,28 +1039,101 @@ func (cfg *apiConfig) HandleCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	respondWithJSON(w, 201, returnVal{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
-}
+	if len(params.MediaIDs) > 0 {
+		attached, err := qtx.AttachMediaToChirp(r.Context(), database.AttachMediaToChirpParams{
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Ids:     params.MediaIDs,
+			UserID:  userId,
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
 
-func filterProfaneWords(src string, profaneList []string) string {
-	splits := strings.Split(src, " ")
+		if attached != int64(len(params.MediaIDs)) {
+			respondWithError(w, 400, "Unknown or already attached media ID")
+			return
+		}
+	}
 
-	for i, word := range splits {
-		for _, profane := range profaneList {
-			if strings.ToLower(word) == profane {
-				splits[i] = "****"
-			}
+	if len(filtered.Flagged) > 0 {
+		_, err := qtx.CreateReport(r.Context(), database.CreateReportParams{
+			UserID:  userId,
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Reason:  reasonFlaggedTerms,
+			Details: strings.Join(filtered.Flagged, ", "),
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
 		}
 	}
 
-	finalText := strings.Join(splits, " ")
-	return finalText
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+	if err := logSpamDecision(r.Context(), qtx, userId, chirpID, verdict); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	// Held chirps stay hidden until a moderator dismisses the report.
+	if verdict.Outcome == spam.Hold {
+		if err := qtx.HideChirp(r.Context(), chirp.ID); err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+		chirp.HiddenAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+
+		_, err := qtx.CreateReport(r.Context(), database.CreateReportParams{
+			UserID:  userId,
+			ChirpID: chirpID,
+			Reason:  reasonSpam,
+			Details: spamDetails(verdict),
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	if params.Poll != nil {
+		if err := createPoll(r.Context(), qtx, chirp.ID, params.Poll.ClosesAt, pollLabels); err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	if err := enqueueChirpEventsJob(r.Context(), qtx, chirp); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.chirpVals(r.Context(), userId, []database.Chirp{chirp}, false)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 201, retVals[0])
+}
+
+// viewerFromRequest returns the user behind an optional bearer token.
+// Anonymous requests get uuid.Nil, which never matches a block or mute row.
+func (cfg *apiConfig) viewerFromRequest(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+
+	user, err := cfg.authenticate(r)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
 }
 
 func respondWithError(w http.ResponseWriter, code int, msg string) {
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/media"
+)
+
+type mediaVal struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+func newMediaVal(m database.ChirpMedium) mediaVal {
+	return mediaVal{
+		ID:           m.ID,
+		ContentType:  m.ContentType,
+		URL:          fmt.Sprintf("/api/media/%s", m.ID),
+		ThumbnailURL: fmt.Sprintf("/api/media/%s/thumbnail", m.ID),
+		Width:        m.Width,
+		Height:       m.Height,
+	}
+}
+
+// HandleUploadMedia accepts a single image in the multipart field "file".
+// The stored copy is re-encoded, so it carries no EXIF data. The returned ID
+// is passed in media_ids when creating a chirp.
+func (cfg *apiConfig) HandleUploadMedia(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+(1<<20))
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, 400, "Expected an image in the \"file\" form field")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	if err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	if len(data) > media.MaxUploadSize {
+		respondWithError(w, 413, "File is too large")
+		return
+	}
+
+	img, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, 415, "Only JPEG, PNG and GIF images are supported")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 400, "Could not read the image")
+		return
+	}
+
+	mediaID := uuid.New()
+	blobKey := fmt.Sprintf("media/%s", mediaID)
+	thumbnailKey := fmt.Sprintf("media/%s_thumb", mediaID)
+
+	if err := cfg.blobs.Put(r.Context(), blobKey, bytes.NewReader(img.Data), img.ContentType); err != nil {
+		log.Printf("Could not store media %v: %v\n", mediaID, err)
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	// Nothing references the files until the row exists, so they are
+	// removed again if it cannot be created. The purge only finds rows.
+	cleanup := context.WithoutCancel(r.Context())
+
+	if err := cfg.blobs.Put(r.Context(), thumbnailKey, bytes.NewReader(img.Thumbnail), img.ThumbnailContentType); err != nil {
+		log.Printf("Could not store thumbnail %v: %v\n", mediaID, err)
+		cfg.deleteBlob(cleanup, blobKey)
+		cfg.deleteBlob(cleanup, thumbnailKey)
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	m, err := cfg.db.CreateChirpMedia(r.Context(), database.CreateChirpMediaParams{
+		ID:                   mediaID,
+		UserID:               userID,
+		ContentType:          img.ContentType,
+		BlobKey:              blobKey,
+		ThumbnailKey:         thumbnailKey,
+		ThumbnailContentType: img.ThumbnailContentType,
+		Width:                int32(img.Width),
+		Height:               int32(img.Height),
+		SizeBytes:            int64(len(img.Data)),
+	})
+	if err != nil {
+		cfg.deleteBlob(cleanup, blobKey)
+		cfg.deleteBlob(cleanup, thumbnailKey)
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 201, newMediaVal(m))
+}
+
+func (cfg *apiConfig) HandleGetMedia(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *apiConfig) HandleGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+// serveMedia streams an attachment. Chirps can be deleted, hidden or made
+// private and authors can block viewers, so even public media is only
+// cached privately and briefly before it has to be revalidated. Uploads
+// that are not attached to a chirp yet are only visible to their owner and
+// are never cached.
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	viewerID, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	m, err := cfg.db.GetChirpMedia(r.Context(), mediaID)
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	cacheControl := "private, max-age=60, must-revalidate"
+	if !m.ChirpID.Valid {
+		if m.UserID != viewerID {
+			respondWithError(w, 404, "Not found")
+			return
+		}
+		cacheControl = "private, no-store"
+	} else {
+		chirp, err := cfg.getVisibleChirp(r.Context(), viewerID, m.ChirpID.UUID)
+		if err != nil {
+			respondWithError(w, 404, "Not found")
+			return
+		}
+
+		if chirp.Status != chirpStatusPublished || (chirp.Visibility != visibilityPublic && chirp.Visibility != visibilityUnlisted) {
+			cacheControl = "private, no-store"
+		}
+	}
+
+	key, contentType, etag := m.BlobKey, m.ContentType, fmt.Sprintf("\"%s\"", m.ID)
+	if thumbnail {
+		key, contentType, etag = m.ThumbnailKey, m.ThumbnailContentType, fmt.Sprintf("\"%s-thumb\"", m.ID)
+	}
+
+	rc, err := cfg.blobs.Get(r.Context(), key)
+	if err != nil {
+		log.Printf("Could not read blob %v: %v\n", key, err)
+		respondWithError(w, 404, "Not found")
+		return
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", m.CreatedAt, bytes.NewReader(data))
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/chirptext"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/encryption"
+	"github.com/paysis/chirpy/internal/pubsub"
+)
+
+const (
+	// maxConversationSize counts every participant, the creator included.
+	maxConversationSize = 10
+	maxMessageLength    = 1000
+)
+
+const streamEventMessage = "message"
+
+// directKey names the one-to-one conversation of a and b, whichever of
+// them starts it.
+func directKey(a, b uuid.UUID) string {
+	if strings.Compare(a.String(), b.String()) > 0 {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+// messageAD is authenticated along with a message body, so that a body
+// only opens in the row it was sealed for.
+func messageAD(conversationID, messageID uuid.UUID) []byte {
+	return append(conversationID[:], messageID[:]...)
+}
+
+// conversationParticipantIDs checks the participants a conversation is
+// started with, leaving out the creator and duplicates.
+func conversationParticipantIDs(creatorID uuid.UUID, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var others []uuid.UUID
+	for _, id := range ids {
+		if id != creatorID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+
+	if len(others) == 0 {
+		return nil, fmt.Errorf("Add at least one other participant")
+	}
+	if len(others)+1 > maxConversationSize {
+		return nil, fmt.Errorf("A conversation can have at most %d participants", maxConversationSize)
+	}
+	return others, nil
+}
+
+// messagesEnabled reports whether direct messages can be used, which
+// needs a key to encrypt them with. It writes the error response itself.
+func (cfg *apiConfig) messagesEnabled(w http.ResponseWriter) bool {
+	if cfg.messageKeys == nil {
+		respondWithError(w, 503, "Direct messages are not available")
+		return false
+	}
+	return true
+}
+
+// validateMessageBody runs a message through the same checks as a chirp
+// body. It returns the body to store and the flagged terms, if any.
+func (cfg *apiConfig) validateMessageBody(body string) (string, []string, error) {
+	body = chirptext.Normalize(body)
+	if body == "" {
+		return "", nil, fmt.Errorf("Message cannot be empty")
+	}
+
+	if chirptext.Length(body) > maxMessageLength {
+		return "", nil, fmt.Errorf("Message is too long, the limit is %d characters", maxMessageLength)
+	}
+
+	filtered := cfg.profanity.Check(body)
+	if filtered.Rejected {
+		return "", nil, fmt.Errorf("Message contains language that is not allowed")
+	}
+
+	return filtered.Text, filtered.Flagged, nil
+}
+
+type participantVal struct {
+	User       authorVal  `json:"user"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type conversationVal struct {
+	ID            uuid.UUID        `json:"id"`
+	IsGroup       bool             `json:"is_group"`
+	Participants  []participantVal `json:"participants"`
+	UnreadCount   int64            `json:"unread_count"`
+	CreatedAt     time.Time        `json:"created_at"`
+	LastMessageAt *time.Time       `json:"last_message_at"`
+}
+
+type messageVal struct {
+	ID             uuid.UUID  `json:"id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	SenderID       *uuid.UUID `json:"sender_id"`
+	Body           string     `json:"body"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (cfg *apiConfig) conversationVals(ctx context.Context, rows []database.ListConversationsRow) ([]conversationVal, error) {
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	participants, err := cfg.db.GetConversationParticipants(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []uuid.UUID
+	for _, p := range participants {
+		userIDs = append(userIDs, p.UserID)
+	}
+
+	summaries, err := cfg.db.GetUserSummaries(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[uuid.UUID]authorVal, len(summaries))
+	for _, s := range summaries {
+		users[s.ID] = authorVal{
+			ID:          s.ID,
+			Handle:      s.Handle,
+			DisplayName: s.DisplayName,
+			AvatarURL:   s.AvatarUrl,
+		}
+	}
+
+	byConversation := map[uuid.UUID][]participantVal{}
+	for _, p := range participants {
+		val := participantVal{User: users[p.UserID]}
+		if p.LastReadAt.Valid {
+			val.LastReadAt = &p.LastReadAt.Time
+		}
+		byConversation[p.ConversationID] = append(byConversation[p.ConversationID], val)
+	}
+
+	retVals := make([]conversationVal, 0, len(rows))
+	for _, row := range rows {
+		val := conversationVal{
+			ID:           row.ID,
+			IsGroup:      !row.DirectKey.Valid,
+			Participants: byConversation[row.ID],
+			UnreadCount:  row.UnreadCount,
+			CreatedAt:    row.CreatedAt,
+		}
+		if row.LastMessageAt.Valid {
+			val.LastMessageAt = &row.LastMessageAt.Time
+		}
+		retVals = append(retVals, val)
+	}
+
+	return retVals, nil
+}
+
+func (cfg *apiConfig) messageVal(ctx context.Context, m database.DirectMessage) (messageVal, error) {
+	body, err := encryption.Open(ctx, cfg.messageKeys, encryption.Sealed{
+		KeyID:      m.KeyID,
+		Nonce:      m.Nonce,
+		Ciphertext: m.Ciphertext,
+	}, messageAD(m.ConversationID, m.ID))
+	if err != nil {
+		return messageVal{}, fmt.Errorf("could not open message %v: %w", m.ID, err)
+	}
+
+	val := messageVal{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		Body:           string(body),
+		CreatedAt:      m.CreatedAt,
+	}
+	if m.SenderID.Valid {
+		val.SenderID = &m.SenderID.UUID
+	}
+	return val, nil
+}
+
+// conversationParticipant authenticates the caller and checks that they
+// take part in the {conversationID} path value. Everyone else gets a 404.
+func (cfg *apiConfig) conversationParticipant(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the conversation ID is of type UUID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	isParticipant, err := cfg.db.IsConversationParticipant(r.Context(), database.IsConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if !isParticipant {
+		respondWithError(w, 404, "Not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, conversationID, true
+}
+
+// HandleCreateConversation starts a conversation with the given users. A
+// single participant makes it one-to-one; starting one that already
+// exists returns it with status 200. Nobody can be added who blocked the
+// caller or was blocked by them.
+func (cfg *apiConfig) HandleCreateConversation(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok || !cfg.messagesEnabled(w) {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	others, err := conversationParticipantIDs(userID, params.ParticipantIDs)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	summaries, err := cfg.db.GetUserSummaries(r.Context(), others)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if len(summaries) != len(others) {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+
+	blocked, err := cfg.db.IsBlockedWithAny(r.Context(), database.IsBlockedWithAnyParams{
+		UserID:   userID,
+		OtherIds: others,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if blocked {
+		respondWithError(w, 403, "You cannot message users you blocked or who blocked you")
+		return
+	}
+
+	var key sql.NullString
+	if len(others) == 1 {
+		key = sql.NullString{String: directKey(userID, others[0]), Valid: true}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	code := 201
+	conversation, err := qtx.CreateConversation(r.Context(), key)
+	if errors.Is(err, sql.ErrNoRows) {
+		code = 200
+		conversation, err = qtx.GetDirectConversation(r.Context(), key)
+	} else if err == nil {
+		err = qtx.AddConversationParticipants(r.Context(), database.AddConversationParticipantsParams{
+			ConversationID: conversation.ID,
+			UserIds:        append(others, userID),
+		})
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	rows, err := cfg.db.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID:         userID,
+		ConversationID: uuid.NullUUID{UUID: conversation.ID, Valid: true},
+		PageSize:       1,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.conversationVals(r.Context(), rows)
+	if err != nil || len(retVals) != 1 {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, code, retVals[0])
+}
+
+// HandleListConversations returns the caller's conversations, the one with
+// the most recent message first.
+func (cfg *apiConfig) HandleListConversations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	rows, err := cfg.db.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID:     userID,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.conversationVals(r.Context(), rows)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+// HandleListMessages returns the messages of a conversation, newest first.
+// New messages would shift offsets, so older pages are asked for with
+// ?before=<message ID> instead.
+func (cfg *apiConfig) HandleListMessages(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok || !cfg.messagesEnabled(w) {
+		return
+	}
+
+	limit, _, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	var before uuid.NullUUID
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, 400, "Please make sure before is a message ID")
+			return
+		}
+		before = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	messages, err := cfg.db.ListDirectMessages(r.Context(), database.ListDirectMessagesParams{
+		ConversationID: conversationID,
+		BeforeID:       before,
+		ViewerID:       userID,
+		PageSize:       limit,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]messageVal, 0, len(messages))
+	for _, m := range messages {
+		val, err := cfg.messageVal(r.Context(), m)
+		if err != nil {
+			log.Println(err)
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+		retVals = append(retVals, val)
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+// HandleSendMessage posts to a conversation. One-to-one conversations
+// refuse messages once either side blocked the other; in groups, blocked
+// senders are only hidden from the users who blocked them.
+func (cfg *apiConfig) HandleSendMessage(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok || !cfg.messagesEnabled(w) {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	body, flagged, err := cfg.validateMessageBody(params.Body)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	participants, err := cfg.db.GetConversationParticipants(r.Context(), []uuid.UUID{conversationID})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	var others []uuid.UUID
+	for _, p := range participants {
+		if p.UserID != userID {
+			others = append(others, p.UserID)
+		}
+	}
+
+	if len(others) == 1 {
+		blocked, err := cfg.db.IsBlockedWithAny(r.Context(), database.IsBlockedWithAnyParams{
+			UserID:   userID,
+			OtherIds: others,
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+
+		if blocked {
+			respondWithError(w, 403, "You cannot message users you blocked or who blocked you")
+			return
+		}
+	}
+
+	messageID := uuid.New()
+	sealed, err := encryption.Seal(r.Context(), cfg.messageKeys, []byte(body), messageAD(conversationID, messageID))
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	message, err := qtx.CreateDirectMessage(r.Context(), database.CreateDirectMessageParams{
+		ID:             messageID,
+		ConversationID: conversationID,
+		SenderID:       uuid.NullUUID{UUID: userID, Valid: true},
+		KeyID:          sealed.KeyID,
+		Nonce:          sealed.Nonce,
+		Ciphertext:     sealed.Ciphertext,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	sentAt := sql.NullTime{Time: message.CreatedAt, Valid: true}
+	err = qtx.TouchConversation(r.Context(), database.TouchConversationParams{
+		ID:            conversationID,
+		LastMessageAt: sentAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	// Senders have read everything up to their own message.
+	err = qtx.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         sentAt,
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	// Moderators cannot read messages, so the report only names the terms.
+	if len(flagged) > 0 {
+		_, err := qtx.CreateReport(r.Context(), database.CreateReportParams{
+			UserID:  userID,
+			Reason:  reasonFlaggedTerms,
+			Details: "direct message: " + strings.Join(flagged, ", "),
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	sender, err := qtx.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	// Messages of shadow-banned users are stored for the sender's own view
+	// but nobody else is told about them.
+	if len(others) > 0 && !sender.ShadowBanned {
+		type eventData struct {
+			ConversationID uuid.UUID `json:"conversation_id"`
+			MessageID      uuid.UUID `json:"message_id"`
+			SenderID       uuid.UUID `json:"sender_id"`
+		}
+
+		// Stream events are not encrypted, so they leave the body out.
+		topics := make([]string, 0, len(others))
+		for _, id := range others {
+			topics = append(topics, notificationTopic(id))
+		}
+		err := pubsub.Publish(r.Context(), qtx, topics, streamEventMessage, eventData{
+			ConversationID: conversationID,
+			MessageID:      message.ID,
+			SenderID:       userID,
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVal, err := cfg.messageVal(r.Context(), message)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 201, retVal)
+}
+
+// HandleMarkConversationRead records a read receipt up to and including
+// the given message. Receipts never move back.
+func (cfg *apiConfig) HandleMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MessageID uuid.UUID `json:"message_id"`
+	}
+
+	userID, conversationID, ok := cfg.conversationParticipant(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	message, err := cfg.db.GetDirectMessage(r.Context(), database.GetDirectMessageParams{
+		ID:             params.MessageID,
+		ConversationID: conversationID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Message not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	err = cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         sql.NullTime{Time: message.CreatedAt, Valid: true},
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
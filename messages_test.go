@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/encryption"
+	"github.com/paysis/chirpy/internal/profanity"
+)
+
+func TestDirectKey(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+
+	if directKey(a, b) != directKey(b, a) {
+		t.Errorf("expected the same key either way, have got: %v and %v\n", directKey(a, b), directKey(b, a))
+	}
+
+	if directKey(a, b) == directKey(a, uuid.New()) {
+		t.Errorf("expected different pairs to have different keys\n")
+	}
+}
+
+func TestConversationParticipantIDs(t *testing.T) {
+	creator := uuid.New()
+	other := uuid.New()
+
+	many := make([]uuid.UUID, maxConversationSize)
+	for i := range many {
+		many[i] = uuid.New()
+	}
+
+	cases := []struct {
+		ids       []uuid.UUID
+		expected  int
+		expectErr bool
+	}{
+		{ids: []uuid.UUID{other}, expected: 1},
+		{ids: []uuid.UUID{other, other, creator}, expected: 1},
+		{ids: many[:maxConversationSize-1], expected: maxConversationSize - 1},
+		{ids: many, expectErr: true},
+		{ids: []uuid.UUID{creator}, expectErr: true},
+		{ids: nil, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			others, err := conversationParticipantIDs(creator, c.ids)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			if len(others) != c.expected {
+				t.Errorf("expected %d participants, have got: %v\n", c.expected, others)
+			}
+		})
+	}
+}
+
+func TestValidateMessageBody(t *testing.T) {
+	cfg := &apiConfig{profanity: profanity.New([]profanity.Term{
+		{Term: "kerfuffle", Action: profanity.ActionMask},
+		{Term: "fornax", Action: profanity.ActionReject},
+		{Term: "sharbert", Action: profanity.ActionFlag},
+	})}
+
+	cases := []struct {
+		body            string
+		expected        string
+		expectedFlagged []string
+		expectErr       bool
+	}{
+		{body: "  hello there ", expected: "hello there"},
+		{body: "what a kerfuffle", expected: "what a " + profanity.Mask},
+		{body: "hello sharbert", expected: "hello sharbert", expectedFlagged: []string{"sharbert"}},
+		{body: "fornax!", expectErr: true},
+		{body: " \u200b ", expectErr: true},
+		{body: strings.Repeat("a", maxMessageLength+1), expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			body, flagged, err := cfg.validateMessageBody(c.body)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			if body != c.expected || fmt.Sprint(flagged) != fmt.Sprint(c.expectedFlagged) {
+				t.Errorf("expected: %q %v, have got: %q %v\n", c.expected, c.expectedFlagged, body, flagged)
+			}
+		})
+	}
+}
+
+func TestMessageVal(t *testing.T) {
+	keys, _ := encryption.NewStaticKeys([]encryption.Key{{ID: "v1", Secret: bytes.Repeat([]byte{7}, encryption.KeySize)}})
+	cfg := &apiConfig{messageKeys: keys}
+
+	conversationID, messageID := uuid.New(), uuid.New()
+	sealed, err := encryption.Seal(context.Background(), keys, []byte("hi there"), messageAD(conversationID, messageID))
+	if err != nil {
+		t.Fatalf("Seal returned err: %v\n", err)
+	}
+
+	message := database.DirectMessage{
+		ID:             messageID,
+		ConversationID: conversationID,
+		KeyID:          sealed.KeyID,
+		Nonce:          sealed.Nonce,
+		Ciphertext:     sealed.Ciphertext,
+	}
+
+	cases := []struct {
+		conversationID uuid.UUID
+		expectErr      bool
+	}{
+		{conversationID: conversationID},
+		// A body copied into another conversation does not open.
+		{conversationID: uuid.New(), expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			m := message
+			m.ConversationID = c.conversationID
+
+			val, err := cfg.messageVal(context.Background(), m)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			if !c.expectErr && (val.Body != "hi there" || val.SenderID != nil) {
+				t.Errorf("expected: hi there without a sender, have got: %+v\n", val)
+			}
+		})
+	}
+}
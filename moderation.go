@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+// suspendUser sets or, with a zero until, lifts a suspension. Suspending
+// also revokes every refresh token, so the user cannot get new JWTs once
+// the suspension ends without logging in again, and closes their live
+// connections.
+func suspendUser(ctx context.Context, qtx *database.Queries, userID uuid.UUID, until time.Time) error {
+	suspendedUntil := sql.NullTime{Time: until, Valid: !until.IsZero()}
+	if err := qtx.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedUntil: suspendedUntil,
+		ID:             userID,
+	}); err != nil {
+		return err
+	}
+
+	if !suspendedUntil.Valid {
+		return nil
+	}
+
+	if err := qtx.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	return publishSessionRevoked(ctx, qtx, userID, "")
+}
+
+// moderationTarget authenticates a moderator and loads the user in the
+// {userID} path value. Admins can only be moderated by other admins.
+func (cfg *apiConfig) moderationTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.User, bool) {
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return uuid.Nil, database.User{}, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the user ID is of type UUID")
+		return uuid.Nil, database.User{}, false
+	}
+
+	target, ok := cfg.checkModerationTarget(w, r, moderatorID, targetID)
+	if !ok {
+		return uuid.Nil, database.User{}, false
+	}
+
+	return moderatorID, target, true
+}
+
+// checkModerationTarget loads the user moderatorID is about to act on and
+// makes sure they may: nobody moderates themselves, and staff accounts are
+// only moderated by admins. It writes the error response itself.
+func (cfg *apiConfig) checkModerationTarget(w http.ResponseWriter, r *http.Request, moderatorID, targetID uuid.UUID) (database.User, bool) {
+	if targetID == moderatorID {
+		respondWithError(w, 400, "You cannot do that to yourself")
+		return database.User{}, false
+	}
+
+	target, err := cfg.db.GetUserById(r.Context(), targetID)
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return database.User{}, false
+	}
+
+	if target.Role != roleUser {
+		moderator, err := cfg.db.GetUserById(r.Context(), moderatorID)
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return database.User{}, false
+		}
+		if moderator.Role != roleAdmin {
+			respondWithError(w, 403, "Only admins can moderate staff accounts")
+			return database.User{}, false
+		}
+	}
+
+	return target, true
+}
+
+// moderateUser runs a change to a user's account together with its
+// moderation log entry in one transaction.
+func (cfg *apiConfig) moderateUser(ctx context.Context, moderatorID, userID uuid.UUID, action, note string, apply func(*database.Queries) error) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if err := apply(qtx); err != nil {
+		return err
+	}
+
+	err = qtx.CreateModerationLog(ctx, database.CreateModerationLogParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      action,
+		UserID:      uuid.NullUUID{UUID: userID, Valid: true},
+		Note:        strings.TrimSpace(note),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := notifyModeration(ctx, qtx, userID, uuid.NullUUID{}, action); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) HandleSuspendUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Until time.Time `json:"until"`
+		Note  string    `json:"note"`
+	}
+
+	moderatorID, target, ok := cfg.moderationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	if !params.Until.After(time.Now()) {
+		respondWithError(w, 400, "until must be in the future")
+		return
+	}
+
+	err := cfg.moderateUser(r.Context(), moderatorID, target.ID, "suspend", params.Note, func(qtx *database.Queries) error {
+		return suspendUser(r.Context(), qtx, target.ID, params.Until.UTC())
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *apiConfig) HandleUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleUserModeration(w, r, "unsuspend", func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		return suspendUser(ctx, qtx, userID, time.Time{})
+	})
+}
+
+func (cfg *apiConfig) HandleShadowBanUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleUserModeration(w, r, "shadow_ban", func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		return qtx.SetUserShadowBanned(ctx, database.SetUserShadowBannedParams{
+			ShadowBanned: true,
+			ID:           userID,
+		})
+	})
+}
+
+func (cfg *apiConfig) HandleUnshadowBanUser(w http.ResponseWriter, r *http.Request) {
+	cfg.handleUserModeration(w, r, "unshadow_ban", func(ctx context.Context, qtx *database.Queries, userID uuid.UUID) error {
+		return qtx.SetUserShadowBanned(ctx, database.SetUserShadowBannedParams{
+			ShadowBanned: false,
+			ID:           userID,
+		})
+	})
+}
+
+// handleUserModeration serves the endpoints whose only input is an
+// optional note for the moderation log.
+func (cfg *apiConfig) handleUserModeration(w http.ResponseWriter, r *http.Request, action string, apply func(context.Context, *database.Queries, uuid.UUID) error) {
+	type parameters struct {
+		Note string `json:"note"`
+	}
+
+	moderatorID, target, ok := cfg.moderationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	err := cfg.moderateUser(r.Context(), moderatorID, target.ID, action, params.Note, func(qtx *database.Queries) error {
+		return apply(r.Context(), qtx, target.ID)
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
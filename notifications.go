@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/pubsub"
+)
+
+const (
+	notificationFollow     = "follow"
+	notificationMention    = "mention"
+	notificationModeration = "moderation"
+)
+
+var notificationTypes = []string{notificationFollow, notificationMention, notificationModeration}
+
+// moderationNotices are the moderation actions the affected user is told
+// about. Dismissals and shadow bans stay silent.
+var moderationNotices = map[string]string{
+	resolutionHideChirp:   "A moderator hid your chirp",
+	resolutionSuspendUser: "Your account was suspended",
+	"suspend":             "Your account was suspended",
+	"unsuspend":           "Your suspension was lifted",
+	"mark_sensitive":      "A moderator marked your chirp as sensitive",
+}
+
+// createNotification stores the notification and tells the user's
+// notification stream about it. Neither happens when the user turned its
+// type off or the actor is shadow-banned.
+func createNotification(ctx context.Context, q *database.Queries, params database.CreateNotificationParams) error {
+	created, err := q.CreateNotification(ctx, params)
+	if err != nil || created == 0 {
+		return err
+	}
+
+	type eventData struct {
+		Type string `json:"type"`
+	}
+
+	return pubsub.Publish(ctx, q, []string{notificationTopic(params.UserID)}, streamEventNotification, eventData{Type: params.Type})
+}
+
+// notifyFollow tells userID that actorID followed them. Follows are grouped
+// until the user reads them.
+func notifyFollow(ctx context.Context, q *database.Queries, userID, actorID uuid.UUID) error {
+	return createNotification(ctx, q, database.CreateNotificationParams{
+		UserID:  userID,
+		Type:    notificationFollow,
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: true},
+	})
+}
+
+func notifyMention(ctx context.Context, q *database.Queries, userID uuid.UUID, chirp database.Chirp) error {
+	return createNotification(ctx, q, database.CreateNotificationParams{
+		UserID:   userID,
+		Type:     notificationMention,
+		GroupKey: chirp.ID.String(),
+		ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ActorID:  uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+	})
+}
+
+// notifyModeration tells userID about a moderation decision, if the action
+// is one they should hear about. Decisions are never grouped.
+func notifyModeration(ctx context.Context, q *database.Queries, userID uuid.UUID, chirpID uuid.NullUUID, action string) error {
+	if _, ok := moderationNotices[action]; !ok {
+		return nil
+	}
+
+	return createNotification(ctx, q, database.CreateNotificationParams{
+		UserID:   userID,
+		Type:     notificationModeration,
+		GroupKey: uuid.NewString(),
+		ChirpID:  chirpID,
+		Details:  action,
+	})
+}
+
+// notificationMessage describes n for display. actor is the handle of the
+// most recent actor.
+func notificationMessage(n database.Notification, actor string) string {
+	if actor == "" {
+		actor = "Someone"
+	} else {
+		actor = "@" + actor
+	}
+
+	switch n.Type {
+	case notificationFollow:
+		if n.ActorCount > 1 {
+			return fmt.Sprintf("%d people followed you", n.ActorCount)
+		}
+		return actor + " followed you"
+	case notificationMention:
+		return actor + " mentioned you"
+	case notificationModeration:
+		return moderationNotices[n.Details]
+	}
+	return ""
+}
+
+type notificationVal struct {
+	ID         uuid.UUID   `json:"id"`
+	Type       string      `json:"type"`
+	Message    string      `json:"message"`
+	ChirpID    *uuid.UUID  `json:"chirp_id"`
+	Actors     []authorVal `json:"actors"`
+	ActorCount int32       `json:"actor_count"`
+	Read       bool        `json:"read"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+func (cfg *apiConfig) notificationVals(ctx context.Context, notifications []database.Notification) ([]notificationVal, error) {
+	var actorIDs []uuid.UUID
+	for _, n := range notifications {
+		actorIDs = append(actorIDs, n.ActorIds...)
+	}
+
+	summaries, err := cfg.db.GetUserSummaries(ctx, actorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	actors := make(map[uuid.UUID]authorVal, len(summaries))
+	for _, s := range summaries {
+		actors[s.ID] = authorVal{
+			ID:          s.ID,
+			Handle:      s.Handle,
+			DisplayName: s.DisplayName,
+			AvatarURL:   s.AvatarUrl,
+		}
+	}
+
+	retVals := make([]notificationVal, 0, len(notifications))
+	for _, n := range notifications {
+		val := notificationVal{
+			ID:         n.ID,
+			Type:       n.Type,
+			Actors:     []authorVal{},
+			ActorCount: n.ActorCount,
+			Read:       n.ReadAt.Valid,
+			CreatedAt:  n.CreatedAt,
+			UpdatedAt:  n.UpdatedAt,
+		}
+
+		if n.ChirpID.Valid {
+			val.ChirpID = &n.ChirpID.UUID
+		}
+
+		for _, id := range n.ActorIds {
+			if actor, ok := actors[id]; ok {
+				val.Actors = append(val.Actors, actor)
+			}
+		}
+
+		latest := ""
+		if len(val.Actors) > 0 {
+			latest = val.Actors[0].Handle
+		}
+		val.Message = notificationMessage(n, latest)
+
+		retVals = append(retVals, val)
+	}
+
+	return retVals, nil
+}
+
+// HandleListNotifications returns the caller's notifications, most
+// recently updated first. ?unread=true leaves out read ones.
+func (cfg *apiConfig) HandleListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	notifications, err := cfg.db.ListNotifications(r.Context(), database.ListNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.notificationVals(r.Context(), notifications)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+// HandleUnreadNotificationCount is meant to be polled. It only counts rows
+// in the partial index of unread notifications.
+func (cfg *apiConfig) HandleUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	type returnVal struct {
+		Count int64 `json:"count"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := cfg.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, 200, returnVal{Count: count})
+}
+
+func (cfg *apiConfig) HandleMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the notification ID is of type UUID")
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	marked, err := cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if marked == 0 {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// HandleMarkAllNotificationsRead marks everything read that was last
+// updated at or before the optional "before" time, which defaults to now.
+func (cfg *apiConfig) HandleMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Before *time.Time `json:"before"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	before := time.Now().UTC()
+	if params.Before != nil {
+		before = params.Before.UTC()
+	}
+
+	_, err := cfg.db.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+		UserID:    userID,
+		UpdatedAt: before,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// notificationSettings lists every notification type, on unless the user
+// turned it off.
+func notificationSettings(prefs []database.NotificationPreference) map[string]bool {
+	settings := make(map[string]bool, len(notificationTypes))
+	for _, t := range notificationTypes {
+		settings[t] = true
+	}
+	for _, p := range prefs {
+		if slices.Contains(notificationTypes, p.Type) {
+			settings[p.Type] = p.Enabled
+		}
+	}
+	return settings
+}
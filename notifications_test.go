@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/paysis/chirpy/internal/database"
+)
+
+func TestNotificationMessage(t *testing.T) {
+	cases := []struct {
+		notification database.Notification
+		actor        string
+		expected     string
+	}{
+		{
+			notification: database.Notification{Type: notificationFollow, ActorCount: 1},
+			actor:        "alice",
+			expected:     "@alice followed you",
+		},
+		{
+			notification: database.Notification{Type: notificationFollow, ActorCount: 5},
+			actor:        "alice",
+			expected:     "5 people followed you",
+		},
+		{
+			notification: database.Notification{Type: notificationMention, ActorCount: 1},
+			actor:        "",
+			expected:     "Someone mentioned you",
+		},
+		{
+			notification: database.Notification{Type: notificationModeration, Details: resolutionHideChirp},
+			expected:     "A moderator hid your chirp",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			output := notificationMessage(c.notification, c.actor)
+			if output != c.expected {
+				t.Errorf("expected: %q, have got: %q\n", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestNotificationSettings(t *testing.T) {
+	cases := []struct {
+		prefs    []database.NotificationPreference
+		expected map[string]bool
+	}{
+		{
+			prefs:    nil,
+			expected: map[string]bool{notificationFollow: true, notificationMention: true, notificationModeration: true},
+		},
+		{
+			prefs: []database.NotificationPreference{
+				{Type: notificationFollow, Enabled: false},
+				{Type: notificationMention, Enabled: true},
+				{Type: "like", Enabled: false},
+			},
+			expected: map[string]bool{notificationFollow: false, notificationMention: true, notificationModeration: true},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			output := notificationSettings(c.prefs)
+			if !maps.Equal(output, c.expected) {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, output)
+			}
+		})
+	}
+}
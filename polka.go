@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paysis/chirpy/internal/auth"
+)
+
+// maxWebhookBody bounds what is read from a webhook before its signature
+// has been checked.
+const maxWebhookBody = 64 << 10
+
+// polkaConfig says how Polka webhooks are authenticated. Signatures are
+// checked against every secret in secrets so keys can be rotated without
+// downtime. The static ApiKey header is only accepted when allowAPIKey is
+// set.
+type polkaConfig struct {
+	secrets     []string
+	tolerance   time.Duration
+	apiKey      string
+	allowAPIKey bool
+}
+
+func polkaConfigFromEnv() polkaConfig {
+	cfg := polkaConfig{
+		tolerance:   durationFromEnv("POLKA_SIGNATURE_TOLERANCE", 5*time.Minute),
+		apiKey:      os.Getenv("POLKA_KEY"),
+		allowAPIKey: os.Getenv("POLKA_ALLOW_API_KEY") == "true",
+	}
+
+	for _, secret := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			cfg.secrets = append(cfg.secrets, secret)
+		}
+	}
+
+	return cfg
+}
+
+// verify reports whether a webhook request with the given raw body really
+// comes from Polka. A signed request is always judged by its signature,
+// even when the ApiKey fallback is enabled.
+func (p polkaConfig) verify(r *http.Request, body []byte, now time.Time) bool {
+	if header := r.Header.Get(auth.SignatureHeader); header != "" || !p.allowAPIKey {
+		return auth.VerifyWebhookSignature(header, body, p.secrets, now, p.tolerance) == nil
+	}
+
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil || p.apiKey == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(apiKey), []byte(p.apiKey)) == 1
+}
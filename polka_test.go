@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/paysis/chirpy/internal/auth"
+)
+
+func TestPolkaVerify(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"user.upgraded"}`)
+	signed := auth.SignatureHeaderValue([]string{"secret"}, now, body)
+
+	cases := []struct {
+		config    polkaConfig
+		signature string
+		apiKey    string
+		expected  bool
+	}{
+		{config: polkaConfig{secrets: []string{"secret"}, tolerance: time.Minute}, signature: signed, expected: true},
+		{config: polkaConfig{secrets: []string{"other"}, tolerance: time.Minute}, signature: signed, expected: false},
+		{config: polkaConfig{secrets: []string{"secret"}, tolerance: time.Minute, apiKey: "key"}, apiKey: "key", expected: false},
+		{config: polkaConfig{tolerance: time.Minute, apiKey: "key", allowAPIKey: true}, apiKey: "key", expected: true},
+		{config: polkaConfig{tolerance: time.Minute, apiKey: "key", allowAPIKey: true}, apiKey: "nope", expected: false},
+		{config: polkaConfig{tolerance: time.Minute, allowAPIKey: true}, apiKey: "", expected: false},
+		{config: polkaConfig{secrets: []string{"other"}, tolerance: time.Minute, apiKey: "key", allowAPIKey: true}, signature: signed, apiKey: "key", expected: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/polka/webhooks", nil)
+			if c.signature != "" {
+				r.Header.Set(auth.SignatureHeader, c.signature)
+			}
+			if c.apiKey != "" {
+				r.Header.Set("Authorization", "ApiKey "+c.apiKey)
+			}
+
+			if got := c.config.verify(r, body, now); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const (
+	minPollOptions     = 2
+	maxPollOptions     = 4
+	maxPollLabelLength = 25
+	minPollDuration    = 5 * time.Minute
+	maxPollDuration    = 7 * 24 * time.Hour
+)
+
+type pollParams struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+type pollOptionVal struct {
+	ID    uuid.UUID `json:"id"`
+	Label string    `json:"label"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+// pollVal hides vote counts until the viewer has voted or the poll has
+// closed, so early results cannot sway the outcome.
+type pollVal struct {
+	ID            uuid.UUID       `json:"id"`
+	ClosesAt      time.Time       `json:"closes_at"`
+	Closed        bool            `json:"closed"`
+	VotedOptionID *uuid.UUID      `json:"voted_option_id,omitempty"`
+	TotalVotes    *int64          `json:"total_votes,omitempty"`
+	Options       []pollOptionVal `json:"options"`
+}
+
+// validatePoll trims the option labels and checks them together with the
+// closing time.
+func validatePoll(p pollParams, now time.Time) ([]string, error) {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return nil, fmt.Errorf("a poll needs %d to %d options", minPollOptions, maxPollOptions)
+	}
+
+	labels := make([]string, 0, len(p.Options))
+	seen := map[string]bool{}
+	for _, option := range p.Options {
+		label := strings.TrimSpace(option)
+		if label == "" {
+			return nil, fmt.Errorf("poll options cannot be empty")
+		}
+		if utf8.RuneCountInString(label) > maxPollLabelLength {
+			return nil, fmt.Errorf("poll options can be at most %d characters", maxPollLabelLength)
+		}
+		if seen[strings.ToLower(label)] {
+			return nil, fmt.Errorf("poll options must be unique")
+		}
+		seen[strings.ToLower(label)] = true
+		labels = append(labels, label)
+	}
+
+	duration := p.ClosesAt.Sub(now)
+	if duration < minPollDuration || duration > maxPollDuration {
+		return nil, fmt.Errorf("a poll must close between %v and %v from now", minPollDuration, maxPollDuration)
+	}
+
+	return labels, nil
+}
+
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, closesAt time.Time, labels []string) error {
+	poll, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: closesAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, label := range labels {
+		err := qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   poll.ID,
+			Position: int32(i),
+			Label:    label,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pollVals loads the polls attached to the given chirps, keyed by chirp ID.
+func (cfg *apiConfig) pollVals(ctx context.Context, viewerID uuid.UUID, chirpIDs []uuid.UUID) (map[uuid.UUID]*pollVal, error) {
+	polls, err := cfg.db.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	retVals := make(map[uuid.UUID]*pollVal, len(polls))
+	if len(polls) == 0 {
+		return retVals, nil
+	}
+
+	pollIDs := make([]uuid.UUID, 0, len(polls))
+	for _, poll := range polls {
+		pollIDs = append(pollIDs, poll.ID)
+	}
+
+	options, err := cfg.db.GetPollOptionsWithVotes(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	votes, err := cfg.db.GetUserPollVotes(ctx, database.GetUserPollVotesParams{
+		UserID:  viewerID,
+		PollIds: pollIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	votedOption := make(map[uuid.UUID]uuid.UUID, len(votes))
+	for _, vote := range votes {
+		votedOption[vote.PollID] = vote.OptionID
+	}
+
+	byPoll := make(map[uuid.UUID][]database.GetPollOptionsWithVotesRow)
+	for _, option := range options {
+		byPoll[option.PollID] = append(byPoll[option.PollID], option)
+	}
+
+	now := time.Now().UTC()
+	for _, poll := range polls {
+		val := &pollVal{
+			ID:       poll.ID,
+			ClosesAt: poll.ClosesAt,
+			Closed:   !poll.ClosesAt.After(now),
+			Options:  []pollOptionVal{},
+		}
+
+		if optionID, ok := votedOption[poll.ID]; ok {
+			val.VotedOptionID = &optionID
+		}
+
+		showResults := val.Closed || val.VotedOptionID != nil
+		var total int64
+		for _, option := range byPoll[poll.ID] {
+			optionVal := pollOptionVal{
+				ID:    option.ID,
+				Label: option.Label,
+			}
+			if showResults {
+				optionVal.Votes = &option.Votes
+			}
+			total += option.Votes
+			val.Options = append(val.Options, optionVal)
+		}
+
+		if showResults {
+			val.TotalVotes = &total
+		}
+
+		retVals[poll.ChirpID] = val
+	}
+
+	return retVals, nil
+}
+
+func (cfg *apiConfig) HandleVotePoll(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the chirp ID is of type UUID")
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	chirp, err := cfg.getVisibleChirp(r.Context(), userID, chirpID)
+	if err != nil || chirp.Status != chirpStatusPublished {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	poll, err := cfg.db.GetPollByChirp(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	// The insert itself checks the option, the closing time and the one
+	// vote rule, so concurrent requests cannot slip past any of them.
+	inserted, err := cfg.db.CastPollVote(r.Context(), database.CastPollVoteParams{
+		UserID:   userID,
+		OptionID: params.OptionID,
+		PollID:   poll.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	polls, err := cfg.pollVals(r.Context(), userID, []uuid.UUID{chirp.ID})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	val := polls[chirp.ID]
+
+	if inserted == 0 {
+		switch {
+		case val.VotedOptionID != nil:
+			respondWithError(w, 409, "You have already voted")
+		case val.Closed:
+			respondWithError(w, 409, "Poll is closed")
+		default:
+			respondWithError(w, 400, "Unknown poll option")
+		}
+		return
+	}
+
+	respondWithJSON(w, 200, val)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestValidatePoll(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		params    pollParams
+		expectErr bool
+	}{
+		{params: pollParams{Options: []string{"yes", "no"}, ClosesAt: now.Add(time.Hour)}},
+		{params: pollParams{Options: []string{" tea ", "coffee", "both", "neither"}, ClosesAt: now.Add(24 * time.Hour)}},
+		{params: pollParams{Options: []string{"only one"}, ClosesAt: now.Add(time.Hour)}, expectErr: true},
+		{params: pollParams{Options: []string{"a", "b", "c", "d", "e"}, ClosesAt: now.Add(time.Hour)}, expectErr: true},
+		{params: pollParams{Options: []string{"yes", "  "}, ClosesAt: now.Add(time.Hour)}, expectErr: true},
+		{params: pollParams{Options: []string{"Yes", "yes"}, ClosesAt: now.Add(time.Hour)}, expectErr: true},
+		{params: pollParams{Options: []string{"yes", "no"}, ClosesAt: now.Add(time.Minute)}, expectErr: true},
+		{params: pollParams{Options: []string{"yes", "no"}, ClosesAt: now.Add(8 * 24 * time.Hour)}, expectErr: true},
+		{params: pollParams{Options: []string{"yes", "this option label is way too long"}, ClosesAt: now.Add(time.Hour)}, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			labels, err := validatePoll(c.params, now)
+
+			if c.expectErr != (err != nil) {
+				t.Errorf("expected error=%v, have got: %v\n", c.expectErr, err)
+			}
+
+			if err == nil && len(labels) != len(c.params.Options) {
+				t.Errorf("expected %d labels, have got: %v\n", len(c.params.Options), labels)
+			}
+		})
+	}
+}
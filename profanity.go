@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/profanity"
+)
+
+type profaneTermVal struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Term      string    `json:"term"`
+	Action    string    `json:"action"`
+}
+
+func newProfaneTermVal(t database.ProfaneTerm) profaneTermVal {
+	return profaneTermVal{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		Term:      t.Term,
+		Action:    t.Action,
+	}
+}
+
+// reloadProfanity replaces the in-memory term list with the one in the
+// database. It runs after every change made through this instance and
+// periodically to pick up changes made through other instances.
+func (cfg *apiConfig) reloadProfanity(ctx context.Context) {
+	rows, err := cfg.db.ListProfaneTerms(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Could not load profane terms: %v\n", err)
+		}
+		return
+	}
+
+	terms := make([]profanity.Term, 0, len(rows))
+	for _, row := range rows {
+		terms = append(terms, profanity.Term{
+			Term:   row.Term,
+			Action: profanity.Action(row.Action),
+		})
+	}
+
+	cfg.profanity.Replace(terms)
+}
+
+func (cfg *apiConfig) HandleListProfaneTerms(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	terms, err := cfg.db.ListProfaneTerms(r.Context())
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]profaneTermVal, 0, len(terms))
+	for _, t := range terms {
+		retVals = append(retVals, newProfaneTermVal(t))
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+type profaneTermParams struct {
+	Term   string `json:"term"`
+	Action string `json:"action"`
+}
+
+// validateProfaneTerm checks the body shared by the create and update
+// endpoints. The action defaults to mask.
+func validateProfaneTerm(params profaneTermParams) (string, profanity.Action, error) {
+	term := strings.TrimSpace(params.Term)
+	if err := profanity.ValidateTerm(term); err != nil {
+		return "", "", err
+	}
+
+	if params.Action == "" {
+		return term, profanity.ActionMask, nil
+	}
+
+	action, err := profanity.ParseAction(params.Action)
+	if err != nil {
+		return "", "", err
+	}
+
+	return term, action, nil
+}
+
+func (cfg *apiConfig) HandleCreateProfaneTerm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	params := profaneTermParams{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	term, action, err := validateProfaneTerm(params)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	dbTerm, err := cfg.db.CreateProfaneTerm(r.Context(), database.CreateProfaneTermParams{
+		Term:   term,
+		Action: string(action),
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Term already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	cfg.reloadProfanity(r.Context())
+	respondWithJSON(w, 201, newProfaneTermVal(dbTerm))
+}
+
+func (cfg *apiConfig) HandleUpdateProfaneTerm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	termID, err := uuid.Parse(r.PathValue("termID"))
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	params := profaneTermParams{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	term, action, err := validateProfaneTerm(params)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	dbTerm, err := cfg.db.UpdateProfaneTerm(r.Context(), database.UpdateProfaneTermParams{
+		Term:   term,
+		Action: string(action),
+		ID:     termID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Term already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	cfg.reloadProfanity(r.Context())
+	respondWithJSON(w, 200, newProfaneTermVal(dbTerm))
+}
+
+func (cfg *apiConfig) HandleDeleteProfaneTerm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	termID, err := uuid.Parse(r.PathValue("termID"))
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	deleted, err := cfg.db.DeleteProfaneTerm(r.Context(), termID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	cfg.reloadProfanity(r.Context())
+	w.WriteHeader(204)
+}
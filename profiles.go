@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+type profileVal struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+func newProfileVal(user database.User) profileVal {
+	return profileVal{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarUrl,
+	}
+}
+
+func (cfg *apiConfig) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	user, err := cfg.db.GetUserByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	blocked, err := cfg.db.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{
+		UserID:  viewerID,
+		OtherID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if blocked {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	respondWithJSON(w, 200, newProfileVal(user))
+}
+
+// HandleUpdateProfile changes the public profile fields. Fields that are
+// omitted from the request body keep their current value. Credentials are
+// changed through HandleUpdateUser instead.
+func (cfg *apiConfig) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	dbParams := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+	}
+
+	if params.Handle != nil {
+		if err := validateHandle(*params.Handle); err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		dbParams.Handle = *params.Handle
+	}
+
+	if params.DisplayName != nil {
+		if utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+			respondWithError(w, 400, "Display name is too long")
+			return
+		}
+		dbParams.DisplayName = *params.DisplayName
+	}
+
+	if params.Bio != nil {
+		if utf8.RuneCountInString(*params.Bio) > maxBioLength {
+			respondWithError(w, 400, "Bio is too long")
+			return
+		}
+		dbParams.Bio = *params.Bio
+	}
+
+	if params.AvatarURL != nil {
+		if *params.AvatarURL != "" && !isValidAvatarURL(*params.AvatarURL) {
+			respondWithError(w, 400, "Avatar URL must be an absolute http(s) URL")
+			return
+		}
+		dbParams.AvatarUrl = *params.AvatarURL
+	}
+
+	dbUser, err := cfg.db.UpdateUserProfile(r.Context(), dbParams)
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "Handle is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, newProfileVal(dbUser))
+}
+
+func isValidAvatarURL(raw string) bool {
+	if len(raw) > maxAvatarURLLength {
+		return false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// preferencesVal holds the settings that only the user themselves can see.
+type preferencesVal struct {
+	ExpandSensitive bool            `json:"expand_sensitive"`
+	Notifications   map[string]bool `json:"notifications"`
+}
+
+func (cfg *apiConfig) preferencesVal(ctx context.Context, user database.User) (preferencesVal, error) {
+	prefs, err := cfg.db.GetNotificationPreferences(ctx, user.ID)
+	if err != nil {
+		return preferencesVal{}, err
+	}
+
+	return preferencesVal{
+		ExpandSensitive: user.ExpandSensitive,
+		Notifications:   notificationSettings(prefs),
+	}, nil
+}
+
+func (cfg *apiConfig) HandleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVal, err := cfg.preferencesVal(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVal)
+}
+
+// HandleUpdatePreferences changes the settings given in the request body and
+// keeps the others. Notification types missing from "notifications" keep
+// their setting too.
+func (cfg *apiConfig) HandleUpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ExpandSensitive *bool           `json:"expand_sensitive"`
+		Notifications   map[string]bool `json:"notifications"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	for notificationType := range params.Notifications {
+		if !slices.Contains(notificationTypes, notificationType) {
+			respondWithError(w, 400, fmt.Sprintf("unknown notification type %q", notificationType))
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	if params.ExpandSensitive != nil {
+		user, err = qtx.SetUserExpandSensitive(r.Context(), database.SetUserExpandSensitiveParams{
+			ExpandSensitive: *params.ExpandSensitive,
+			ID:              user.ID,
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	for notificationType, enabled := range params.Notifications {
+		err := qtx.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  user.ID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVal, err := cfg.preferencesVal(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVal)
+}
+
+type subscriptionVal struct {
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	GraceUntil       time.Time `json:"grace_until"`
+}
+
+// HandleGetMe returns the caller's own account, including the private
+// fields and what their plan lets them do.
+func (cfg *apiConfig) HandleGetMe(w http.ResponseWriter, r *http.Request) {
+	type returnVal struct {
+		profileVal
+		UpdatedAt    time.Time        `json:"updated_at"`
+		Email        string           `json:"email"`
+		Role         string           `json:"role"`
+		IsChirpyRed  bool             `json:"is_chirpy_red"`
+		Subscription *subscriptionVal `json:"subscription"`
+		Entitlements entitlementsVal  `json:"entitlements"`
+		Preferences  preferencesVal   `json:"preferences"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	ents, err := cfg.userEntitlements(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	prefs, err := cfg.preferencesVal(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVal := returnVal{
+		profileVal:   newProfileVal(user),
+		UpdatedAt:    user.UpdatedAt,
+		Email:        user.Email,
+		Role:         user.Role,
+		IsChirpyRed:  user.IsChirpyRed,
+		Entitlements: newEntitlementsVal(ents),
+		Preferences:  prefs,
+	}
+
+	sub, err := cfg.db.GetSubscription(r.Context(), user.ID)
+	if err == nil {
+		retVal.Subscription = &subscriptionVal{
+			Plan:             sub.Plan,
+			Status:           sub.Status,
+			CurrentPeriodEnd: sub.CurrentPeriodEnd,
+			GraceUntil:       sub.GraceUntil,
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVal)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/auth"
+	"github.com/paysis/chirpy/internal/entitlements"
+	"github.com/paysis/chirpy/internal/ratelimit"
+)
+
+// rateLimitGroup is a set of routes that share a quota. Anonymous callers
+// are keyed by client IP, logged in ones by user ID. The red limit applies
+// to users with the higher_rate_limits entitlement.
+type rateLimitGroup struct {
+	name      string
+	anonymous ratelimit.Limit
+	user      ratelimit.Limit
+	red       ratelimit.Limit
+}
+
+var (
+	rateLimitSignup = rateLimitGroup{
+		name:      "signup",
+		anonymous: ratelimit.Limit{Burst: 5, Period: time.Hour},
+	}
+	rateLimitAuth = rateLimitGroup{
+		name:      "auth",
+		anonymous: ratelimit.Limit{Burst: 10, Period: time.Minute},
+	}
+	rateLimitRead = rateLimitGroup{
+		name:      "read",
+		anonymous: ratelimit.Limit{Burst: 60, Period: time.Minute},
+		user:      ratelimit.Limit{Burst: 300, Period: time.Minute},
+		red:       ratelimit.Limit{Burst: 600, Period: time.Minute},
+	}
+	rateLimitPost = rateLimitGroup{
+		name: "post",
+		user: ratelimit.Limit{Burst: 10, Period: time.Minute},
+		red:  ratelimit.Limit{Burst: 30, Period: time.Minute},
+	}
+	// Messages have their own quota so that chatting does not use up the
+	// one for chirps.
+	rateLimitMessage = rateLimitGroup{
+		name: "message",
+		user: ratelimit.Limit{Burst: 30, Period: time.Minute},
+		red:  ratelimit.Limit{Burst: 60, Period: time.Minute},
+	}
+	rateLimitUpload = rateLimitGroup{
+		name: "upload",
+		user: ratelimit.Limit{Burst: 10, Period: time.Minute},
+		red:  ratelimit.Limit{Burst: 30, Period: time.Minute},
+	}
+	rateLimitWrite = rateLimitGroup{
+		name: "write",
+		user: ratelimit.Limit{Burst: 60, Period: time.Minute},
+		red:  ratelimit.Limit{Burst: 120, Period: time.Minute},
+	}
+)
+
+// rateLimitIdle is how long a bucket can sit unused before it is swept. It
+// must be at least the longest Period above, after which a bucket is full.
+const rateLimitIdle = time.Hour
+
+// rateTierTTL is how long a user's limit tier is cached, and so how long a
+// new or lapsed entitlement takes to change the limits they get.
+const rateTierTTL = time.Minute
+
+// rateLimit wraps next with the quota of group. Groups without an
+// anonymous limit leave anonymous requests to the handler, which rejects
+// them anyway. If the store fails the request is let through.
+func (cfg *apiConfig) rateLimit(group rateLimitGroup, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, limit, ok := cfg.rateLimitKey(r, group)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		res, err := cfg.rateLimits.Take(r.Context(), key, limit)
+		if err != nil {
+			log.Printf("Could not check rate limit for %v: %v\n", key, err)
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, int(limit.Period.Seconds())))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+		if !res.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			respondWithError(w, 429, "Too many requests")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// rateLimitKey picks the bucket and limit for a request. The JWT is only
+// checked for its signature here; the handler still authenticates fully.
+func (cfg *apiConfig) rateLimitKey(r *http.Request, group rateLimitGroup) (string, ratelimit.Limit, bool) {
+	if group.user.Burst > 0 {
+		if token, err := auth.GetBearerToken(r.Header); err == nil {
+			if userID, err := auth.ValidateJWT(token, cfg.jwtSecret); err == nil {
+				limit := group.user
+				if group.red.Burst > 0 {
+					if cfg.rateTiers.get(userID, time.Now(), func() (bool, error) {
+						return cfg.hasHigherRateLimits(r.Context(), userID)
+					}) {
+						limit = group.red
+					}
+				}
+				return group.name + ":user:" + userID.String(), limit, true
+			}
+		}
+	}
+
+	if group.anonymous.Burst == 0 {
+		return "", ratelimit.Limit{}, false
+	}
+
+	return group.name + ":ip:" + clientIP(r, cfg.trustProxy), group.anonymous, true
+}
+
+func (cfg *apiConfig) hasHigherRateLimits(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := cfg.db.GetUserById(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	ents, err := cfg.userEntitlements(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return ents.Has(entitlements.HigherRateLimits), nil
+}
+
+// rateTierCache remembers for rateTierTTL whether users get the red
+// limits, which would otherwise cost two queries on every request. The
+// zero value is ready to use.
+type rateTierCache struct {
+	mu      sync.Mutex
+	entries map[uuid.UUID]rateTier
+}
+
+type rateTier struct {
+	red     bool
+	expires time.Time
+}
+
+// get returns the cached tier of userID, calling load when there is none
+// or it has expired. A failed load gives the default limits and is not
+// cached.
+func (c *rateTierCache) get(userID uuid.UUID, now time.Time, load func() (bool, error)) bool {
+	c.mu.Lock()
+	tier, ok := c.entries[userID]
+	c.mu.Unlock()
+	if ok && now.Before(tier.expires) {
+		return tier.red
+	}
+
+	red, err := load()
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[uuid.UUID]rateTier{}
+	}
+	c.entries[userID] = rateTier{red: red, expires: now.Add(rateTierTTL)}
+	return red
+}
+
+// sweep drops the tiers that have expired.
+func (c *rateTierCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for userID, tier := range c.entries {
+		if !now.Before(tier.expires) {
+			delete(c.entries, userID)
+		}
+	}
+}
+
+// clientIP returns the caller's address. Behind a reverse proxy the last
+// X-Forwarded-For entry is the one the proxy itself added.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func (cfg *apiConfig) sweepRateLimits(ctx context.Context) {
+	cfg.rateTiers.sweep(time.Now())
+	if err := cfg.rateLimits.Sweep(ctx, rateLimitIdle); err != nil && ctx.Err() == nil {
+		log.Printf("Could not sweep rate limit buckets: %v\n", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	cfg := &apiConfig{
+		rateLimits: ratelimit.NewMemoryStore(),
+		jwtSecret:  "secret",
+	}
+	group := rateLimitGroup{
+		name:      "test",
+		anonymous: ratelimit.Limit{Burst: 2, Period: time.Minute},
+	}
+	handler := cfg.rateLimit(group, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(204)
+	})
+
+	cases := []struct {
+		remoteAddr        string
+		expectedStatus    int
+		expectedRemaining string
+	}{
+		{remoteAddr: "192.0.2.1:1234", expectedStatus: 204, expectedRemaining: "1"},
+		{remoteAddr: "192.0.2.1:5678", expectedStatus: 204, expectedRemaining: "0"},
+		{remoteAddr: "192.0.2.1:1234", expectedStatus: 429, expectedRemaining: "0"},
+		{remoteAddr: "192.0.2.2:1234", expectedStatus: 204, expectedRemaining: "1"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/users", nil)
+			r.RemoteAddr = c.remoteAddr
+			w := httptest.NewRecorder()
+
+			handler(w, r)
+
+			if w.Code != c.expectedStatus {
+				t.Fatalf("expected status %d, have got: %d\n", c.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("RateLimit-Remaining"); got != c.expectedRemaining {
+				t.Errorf("expected RateLimit-Remaining %v, have got: %v\n", c.expectedRemaining, got)
+			}
+			if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+				t.Errorf("expected RateLimit-Limit 2, have got: %v\n", got)
+			}
+			if c.expectedStatus == 429 && w.Header().Get("Retry-After") != "30" {
+				t.Errorf("expected Retry-After 30, have got: %v\n", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+// unlimitedRoutes are the API routes that are deliberately left without a
+// rate limit, along with everything under /api/moderation/, which only
+// staff can use.
+var unlimitedRoutes = map[string]bool{
+	"POST /api/revoke":         true,
+	"POST /api/polka/webhooks": true,
+	"GET /api/healthz":         true,
+}
+
+// TestRoutesAreRateLimited reads the routes registered in main.go and
+// fails for every /api route whose handler is not wrapped in rateLimit.
+func TestRoutesAreRateLimited(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("could not parse main.go: %v\n", err)
+	}
+
+	routes := 0
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 2 {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); !ok || sel.Sel.Name != "HandleFunc" {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+
+		pattern, err := strconv.Unquote(lit.Value)
+		if err != nil || !strings.Contains(pattern, " /api/") {
+			return true
+		}
+		routes++
+
+		if unlimitedRoutes[pattern] || strings.Contains(pattern, " /api/moderation/") {
+			return true
+		}
+
+		wrapped := false
+		if handler, ok := call.Args[1].(*ast.CallExpr); ok {
+			if sel, ok := handler.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "rateLimit" {
+				wrapped = true
+			}
+		}
+		if !wrapped {
+			t.Errorf("expected %v to be wrapped in rateLimit\n", pattern)
+		}
+		return true
+	})
+
+	if routes == 0 {
+		t.Fatalf("expected to find the /api routes in main.go\n")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		remoteAddr string
+		forwarded  string
+		trustProxy bool
+		expectedIP string
+	}{
+		{remoteAddr: "192.0.2.1:1234", expectedIP: "192.0.2.1"},
+		{remoteAddr: "[2001:db8::1]:1234", expectedIP: "2001:db8::1"},
+		{remoteAddr: "192.0.2.1:1234", forwarded: "198.51.100.7", expectedIP: "192.0.2.1"},
+		{remoteAddr: "192.0.2.1:1234", forwarded: "203.0.113.9, 198.51.100.7", trustProxy: true, expectedIP: "198.51.100.7"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = c.remoteAddr
+			if c.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", c.forwarded)
+			}
+
+			if ip := clientIP(r, c.trustProxy); ip != c.expectedIP {
+				t.Errorf("expected %v, have got: %v\n", c.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestRateTierCache(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	cache := rateTierCache{}
+
+	cases := []struct {
+		now           time.Time
+		red           bool
+		loadErr       error
+		expectedRed   bool
+		expectedLoads int
+	}{
+		{now: start, red: true, expectedRed: true, expectedLoads: 1},
+		// Cached, so the changed tier is not seen yet.
+		{now: start.Add(rateTierTTL / 2), red: false, expectedRed: true, expectedLoads: 1},
+		{now: start.Add(rateTierTTL), red: false, expectedRed: false, expectedLoads: 2},
+		// Errors give the default limits and are not cached.
+		{now: start.Add(3 * rateTierTTL), loadErr: errors.New("db down"), expectedRed: false, expectedLoads: 3},
+		{now: start.Add(3 * rateTierTTL), red: true, expectedRed: true, expectedLoads: 4},
+	}
+
+	loads := 0
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			red := cache.get(userID, c.now, func() (bool, error) {
+				loads++
+				return c.red, c.loadErr
+			})
+
+			if red != c.expectedRed {
+				t.Errorf("expected: %v, have got: %v\n", c.expectedRed, red)
+			}
+			if loads != c.expectedLoads {
+				t.Errorf("expected %d loads, have got: %d\n", c.expectedLoads, loads)
+			}
+		})
+	}
+
+	cache.sweep(start.Add(5 * rateTierTTL))
+	if len(cache.entries) != 0 {
+		t.Errorf("expected expired tiers to be swept, have got: %v\n", cache.entries)
+	}
+}
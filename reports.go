@@ -0,0 +1,581 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const (
+	reportStatusOpen     = "open"
+	reportStatusClaimed  = "claimed"
+	reportStatusResolved = "resolved"
+)
+
+const (
+	resolutionDismiss     = "dismiss"
+	resolutionHideChirp   = "hide_chirp"
+	resolutionSuspendUser = "suspend_user"
+)
+
+// reasonFlaggedTerms is used for reports the system raises itself and
+// cannot be chosen by users.
+const reasonFlaggedTerms = "flagged_terms"
+
+const reasonSpam = "spam"
+
+var reportReasons = map[string]bool{
+	reasonSpam:      true,
+	"harassment":    true,
+	"hate":          true,
+	"violence":      true,
+	"sexual":        true,
+	"self_harm":     true,
+	"impersonation": true,
+	"other":         true,
+}
+
+const (
+	maxReportDetailsLength = 1000
+	defaultSuspension      = 7 * 24 * time.Hour
+	defaultPageSize        = 50
+	maxPageSize            = 100
+)
+
+type reportVal struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ReporterID *uuid.UUID `json:"reporter_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ClaimedBy  *uuid.UUID `json:"claimed_by,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func newReportVal(r database.Report) reportVal {
+	val := reportVal{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		ReporterID: nullUUIDPtr(r.ReporterID),
+		UserID:     r.UserID,
+		ChirpID:    nullUUIDPtr(r.ChirpID),
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ClaimedBy:  nullUUIDPtr(r.ClaimedBy),
+		ResolvedBy: nullUUIDPtr(r.ResolvedBy),
+		Resolution: r.Resolution.String,
+	}
+	if r.ResolvedAt.Valid {
+		val.ResolvedAt = &r.ResolvedAt.Time
+	}
+	return val
+}
+
+type reportParams struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+func validateReport(params reportParams) (reportParams, error) {
+	if !reportReasons[params.Reason] {
+		return params, fmt.Errorf("unknown reason %q", params.Reason)
+	}
+
+	params.Details = strings.TrimSpace(params.Details)
+	if len([]rune(params.Details)) > maxReportDetailsLength {
+		return params, fmt.Errorf("details can be at most %d characters", maxReportDetailsLength)
+	}
+
+	return params, nil
+}
+
+// pageParams reads the ?limit= and ?offset= query parameters.
+func pageParams(r *http.Request) (int32, int32, error) {
+	limit, offset := int32(defaultPageSize), int32(0)
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = int32(n)
+	}
+
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset must be a positive number")
+		}
+		offset = int32(n)
+	}
+
+	return limit, offset, nil
+}
+
+func (cfg *apiConfig) HandleReportChirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the chirp ID is of type UUID")
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	params := reportParams{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	params, err = validateReport(params)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	chirp, err := cfg.getVisibleChirp(r.Context(), userID, chirpID)
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	if chirp.UserID == userID {
+		respondWithError(w, 400, "You cannot report your own chirp")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, err := qtx.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		UserID:     chirp.UserID,
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "You have already reported this chirp")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	hidden, err := qtx.HideReportedChirp(r.Context(), database.HideReportedChirpParams{
+		ID:        chirp.ID,
+		Threshold: cfg.reportHideThreshold,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if hidden > 0 {
+		err := qtx.CreateModerationLog(r.Context(), database.CreateModerationLogParams{
+			Action:   "auto_hide",
+			ReportID: uuid.NullUUID{UUID: report.ID, Valid: true},
+			UserID:   uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Note:     fmt.Sprintf("Reached %d open reports", cfg.reportHideThreshold),
+		})
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 201, newReportVal(report))
+}
+
+func (cfg *apiConfig) HandleReportUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := reportParams{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	params, err := validateReport(params)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	report, err := cfg.db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		UserID:     targetID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, 409, "You have already reported this user")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 201, newReportVal(report))
+}
+
+// HandleListReports returns the moderation queue, oldest first. By default
+// it lists open and claimed reports; ?status= picks a single status.
+func (cfg *apiConfig) HandleListReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	statuses := []string{reportStatusOpen, reportStatusClaimed}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case reportStatusOpen, reportStatusClaimed, reportStatusResolved:
+		statuses = []string{status}
+	default:
+		respondWithError(w, 400, fmt.Sprintf("unknown status %q", status))
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	reports, err := cfg.db.ListReports(r.Context(), database.ListReportsParams{
+		Statuses:   statuses,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]reportVal, 0, len(reports))
+	for _, report := range reports {
+		retVals = append(retVals, newReportVal(report))
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+// claimReport claims an open report, or keeps a claim the moderator already
+// holds. It writes the error response itself when the report is missing,
+// resolved or claimed by someone else.
+func claimReport(w http.ResponseWriter, r *http.Request, qtx *database.Queries, moderatorID, reportID uuid.UUID) (database.Report, bool) {
+	report, err := qtx.ClaimReport(r.Context(), database.ClaimReportParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ID:          reportID,
+	})
+	if err == nil {
+		return report, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 500, "Something went wrong")
+		return report, false
+	}
+
+	report, err = qtx.GetReport(r.Context(), reportID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		respondWithError(w, 404, "Not found")
+	case err != nil:
+		respondWithError(w, 500, "Something went wrong")
+	case report.Status == reportStatusResolved:
+		respondWithError(w, 409, "Report is already resolved")
+	default:
+		respondWithError(w, 409, "Report is claimed by another moderator")
+	}
+	return report, false
+}
+
+func (cfg *apiConfig) HandleClaimReport(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, ok := claimReport(w, r, qtx, moderatorID, reportID)
+	if !ok {
+		return
+	}
+
+	err = qtx.CreateModerationLog(r.Context(), database.CreateModerationLogParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      "claim",
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		UserID:      uuid.NullUUID{UUID: report.UserID, Valid: true},
+		ChirpID:     report.ChirpID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, newReportVal(report))
+}
+
+// HandleResolveReport applies a moderator's decision. It resolves every
+// unresolved report against the same chirp or user along with the one in
+// the path. Dismissing a chirp report also lifts an automatic hide, but
+// not one a moderator placed.
+func (cfg *apiConfig) HandleResolveReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action       string `json:"action"`
+		Note         string `json:"note"`
+		SuspendHours int    `json:"suspend_hours"`
+	}
+
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	suspension := defaultSuspension
+	switch params.Action {
+	case resolutionDismiss, resolutionHideChirp:
+	case resolutionSuspendUser:
+		if params.SuspendHours < 0 {
+			respondWithError(w, 400, "suspend_hours cannot be negative")
+			return
+		}
+		if params.SuspendHours > 0 {
+			suspension = time.Duration(params.SuspendHours) * time.Hour
+		}
+	default:
+		respondWithError(w, 400, fmt.Sprintf("unknown action %q", params.Action))
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	report, ok := claimReport(w, r, qtx, moderatorID, reportID)
+	if !ok {
+		return
+	}
+
+	// Reports about yourself go to another moderator, and suspending
+	// staff takes an admin, the same as on the moderation endpoints.
+	if report.UserID == moderatorID {
+		respondWithError(w, 400, "You cannot resolve reports about yourself")
+		return
+	}
+
+	if params.Action == resolutionSuspendUser {
+		if _, ok := cfg.checkModerationTarget(w, r, moderatorID, report.UserID); !ok {
+			return
+		}
+	}
+
+	if params.Action == resolutionHideChirp && !report.ChirpID.Valid {
+		respondWithError(w, 400, "Only chirp reports can hide a chirp")
+		return
+	}
+
+	resolved, err := qtx.ResolveReports(r.Context(), database.ResolveReportsParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Resolution:  sql.NullString{String: params.Action, Valid: true},
+		UserID:      report.UserID,
+		ChirpID:     report.ChirpID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	switch params.Action {
+	case resolutionDismiss:
+		if report.ChirpID.Valid {
+			err = liftAutomaticHide(r.Context(), qtx, moderatorID, report)
+		}
+	case resolutionHideChirp:
+		err = qtx.HideChirp(r.Context(), report.ChirpID.UUID)
+	case resolutionSuspendUser:
+		err = suspendUser(r.Context(), qtx, report.UserID, time.Now().UTC().Add(suspension))
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	err = qtx.CreateModerationLog(r.Context(), database.CreateModerationLogParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      params.Action,
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		UserID:      uuid.NullUUID{UUID: report.UserID, Valid: true},
+		ChirpID:     report.ChirpID,
+		Note:        strings.TrimSpace(params.Note),
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := notifyModeration(r.Context(), qtx, report.UserID, report.ChirpID, params.Action); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	for _, res := range resolved {
+		if res.ID == report.ID {
+			report = res
+		}
+	}
+
+	respondWithJSON(w, 200, newReportVal(report))
+}
+
+// liftAutomaticHide unhides the reported chirp if the system hid it and
+// records that in the moderation log.
+func liftAutomaticHide(ctx context.Context, qtx *database.Queries, moderatorID uuid.UUID, report database.Report) error {
+	lifted, err := qtx.LiftAutomaticHide(ctx, report.ChirpID.UUID)
+	if err != nil || lifted == 0 {
+		return err
+	}
+
+	return qtx.CreateModerationLog(ctx, database.CreateModerationLogParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      "unhide",
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		UserID:      uuid.NullUUID{UUID: report.UserID, Valid: true},
+		ChirpID:     report.ChirpID,
+		Note:        "Lifted automatic hide",
+	})
+}
+
+type moderationLogVal struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ModeratorID *uuid.UUID `json:"moderator_id"`
+	Action      string     `json:"action"`
+	ReportID    *uuid.UUID `json:"report_id,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	ChirpID     *uuid.UUID `json:"chirp_id,omitempty"`
+	Note        string     `json:"note"`
+}
+
+func (cfg *apiConfig) HandleListModerationLog(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	entries, err := cfg.db.ListModerationLog(r.Context(), database.ListModerationLogParams{
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]moderationLogVal, 0, len(entries))
+	for _, e := range entries {
+		retVals = append(retVals, moderationLogVal{
+			ID:          e.ID,
+			CreatedAt:   e.CreatedAt,
+			ModeratorID: nullUUIDPtr(e.ModeratorID),
+			Action:      e.Action,
+			ReportID:    nullUUIDPtr(e.ReportID),
+			UserID:      nullUUIDPtr(e.UserID),
+			ChirpID:     nullUUIDPtr(e.ChirpID),
+			Note:        e.Note,
+		})
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
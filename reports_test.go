@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateReport(t *testing.T) {
+	cases := []struct {
+		params    reportParams
+		expectErr bool
+	}{
+		{params: reportParams{Reason: "spam"}},
+		{params: reportParams{Reason: "harassment", Details: "  keeps replying to me  "}},
+		{params: reportParams{Reason: ""}, expectErr: true},
+		{params: reportParams{Reason: "boring"}, expectErr: true},
+		{params: reportParams{Reason: reasonFlaggedTerms}, expectErr: true},
+		{params: reportParams{Reason: "other", Details: strings.Repeat("a", maxReportDetailsLength+1)}, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			params, err := validateReport(c.params)
+
+			if c.expectErr != (err != nil) {
+				t.Errorf("expected error=%v, have got: %v\n", c.expectErr, err)
+			}
+
+			if err == nil && params.Details != strings.TrimSpace(c.params.Details) {
+				t.Errorf("expected trimmed details, have got: %q\n", params.Details)
+			}
+		})
+	}
+}
+
+func TestPageParams(t *testing.T) {
+	cases := []struct {
+		query          string
+		expectedLimit  int32
+		expectedOffset int32
+		expectErr      bool
+	}{
+		{query: "", expectedLimit: defaultPageSize, expectedOffset: 0},
+		{query: "?limit=10&offset=20", expectedLimit: 10, expectedOffset: 20},
+		{query: "?limit=0", expectErr: true},
+		{query: "?limit=1000", expectErr: true},
+		{query: "?offset=-1", expectErr: true},
+		{query: "?limit=ten", expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/moderation/reports"+c.query, nil)
+			limit, offset, err := pageParams(r)
+
+			if c.expectErr != (err != nil) {
+				t.Fatalf("expected error=%v, have got: %v\n", c.expectErr, err)
+			}
+
+			if err == nil && (limit != c.expectedLimit || offset != c.expectedOffset) {
+				t.Errorf("expected %d/%d, have got: %d/%d\n", c.expectedLimit, c.expectedOffset, limit, offset)
+			}
+		})
+	}
+}
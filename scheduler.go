@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const scheduledPublishBatch = 100
+
+// HandlePublishChirp publishes one of the caller's drafts or scheduled
+// chirps. Passing publish_at (re)schedules it instead.
+func (cfg *apiConfig) HandlePublishChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the chirp ID is of type UUID")
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil || chirp.UserID != userID {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	status, publishAt, err := resolveChirpStatus("", params.PublishAt, time.Now().UTC())
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err = qtx.UpdateChirpStatus(r.Context(), database.UpdateChirpStatusParams{
+		Status:    status,
+		PublishAt: publishAt,
+		ID:        chirp.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 409, "Chirp is already published")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := enqueueChirpEventsJob(r.Context(), qtx, chirp); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.chirpVals(r.Context(), userID, []database.Chirp{chirp}, false)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVals[0])
+}
+
+// publishDueChirps publishes the scheduled chirps that are due. Rows are
+// claimed with FOR UPDATE SKIP LOCKED, so a chirp is never published twice
+// even when a slow run overlaps the next one.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) error {
+	for {
+		chirps, err := cfg.publishDueBatch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(chirps) > 0 {
+			log.Printf("Published %d scheduled chirps\n", len(chirps))
+		}
+
+		if len(chirps) < scheduledPublishBatch {
+			return nil
+		}
+	}
+}
+
+// publishDueBatch publishes one batch and enqueues the jobs that send its
+// webhook events in the same transaction.
+func (cfg *apiConfig) publishDueBatch(ctx context.Context) ([]database.Chirp, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirps, err := qtx.PublishDueChirps(ctx, scheduledPublishBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chirp := range chirps {
+		if err := enqueueChirpEventsJob(ctx, qtx, chirp); err != nil {
+			return nil, err
+		}
+	}
+
+	return chirps, tx.Commit()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/chirptext"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const maxContentWarningLength = 100
+
+// validateContentWarning normalizes a content warning the same way as chirp
+// bodies and checks it against the length and profanity rules.
+func (cfg *apiConfig) validateContentWarning(raw string) (string, error) {
+	warning := chirptext.Normalize(raw)
+	if chirptext.Length(warning) > maxContentWarningLength {
+		return "", errors.New("Content warning is too long")
+	}
+
+	filtered := cfg.profanity.Check(warning)
+	if filtered.Rejected {
+		return "", errors.New("Content warning contains language that is not allowed")
+	}
+
+	return filtered.Text, nil
+}
+
+// isCollapsed reports whether chirp should be shown collapsed to viewerID.
+// Authors always see their own chirps expanded, anonymous viewers never do.
+func isCollapsed(chirp database.Chirp, viewerID uuid.UUID, expandSensitive bool) bool {
+	if !chirp.Sensitive && chirp.ContentWarning == "" {
+		return false
+	}
+	if chirp.UserID == viewerID {
+		return false
+	}
+	return !expandSensitive
+}
+
+// HandleSetChirpSensitivity lets moderators set or clear the content
+// warning and sensitive flag of any chirp.
+func (cfg *apiConfig) HandleSetChirpSensitivity(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Sensitive      bool   `json:"sensitive"`
+		ContentWarning string `json:"content_warning"`
+		Note           string `json:"note"`
+	}
+
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the chirp ID is of type UUID")
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	warning, err := cfg.validateContentWarning(params.ContentWarning)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := qtx.SetChirpSensitivity(r.Context(), database.SetChirpSensitivityParams{
+		ContentWarning: warning,
+		Sensitive:      params.Sensitive,
+		ID:             chirpID,
+	})
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	action := "mark_sensitive"
+	if !chirp.Sensitive && chirp.ContentWarning == "" {
+		action = "unmark_sensitive"
+	}
+
+	err = qtx.CreateModerationLog(r.Context(), database.CreateModerationLogParams{
+		ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Action:      action,
+		UserID:      uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Note:        strings.TrimSpace(params.Note),
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	err = notifyModeration(r.Context(), qtx, chirp.UserID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, action)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals, err := cfg.chirpVals(r.Context(), moderatorID, []database.Chirp{chirp}, false)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, retVals[0])
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+func TestIsCollapsed(t *testing.T) {
+	author := uuid.New()
+	reader := uuid.New()
+
+	cases := []struct {
+		chirp           database.Chirp
+		viewerID        uuid.UUID
+		expandSensitive bool
+		expected        bool
+	}{
+		{chirp: database.Chirp{UserID: author}, viewerID: uuid.Nil, expected: false},
+		{chirp: database.Chirp{UserID: author, Sensitive: true}, viewerID: uuid.Nil, expected: true},
+		{chirp: database.Chirp{UserID: author, ContentWarning: "spoilers"}, viewerID: uuid.Nil, expected: true},
+		{chirp: database.Chirp{UserID: author, Sensitive: true}, viewerID: reader, expected: true},
+		{chirp: database.Chirp{UserID: author, Sensitive: true}, viewerID: reader, expandSensitive: true, expected: false},
+		{chirp: database.Chirp{UserID: author, ContentWarning: "spoilers"}, viewerID: author, expected: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if got := isCollapsed(c.chirp, c.viewerID, c.expandSensitive); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/auth"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+var errSuspended = errors.New("account is suspended")
+
+func isSuspended(suspendedUntil sql.NullTime, now time.Time) bool {
+	return suspendedUntil.Valid && suspendedUntil.Time.After(now)
+}
+
+// authenticate resolves the bearer JWT to its user. The user is loaded on
+// every request so that a suspension also rejects tokens issued before it.
+func (cfg *apiConfig) authenticate(r *http.Request) (database.User, error) {
+	user, _, err := cfg.authenticateSession(r)
+	return user, err
+}
+
+// authenticateSession is authenticate for callers that also need the
+// claims of the JWT, such as when it expires.
+func (cfg *apiConfig) authenticateSession(r *http.Request) (database.User, auth.Claims, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return database.User{}, auth.Claims{}, err
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+	if err != nil {
+		return database.User{}, auth.Claims{}, err
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), claims.UserID)
+	if err != nil {
+		return database.User{}, auth.Claims{}, err
+	}
+
+	if isSuspended(user.SuspendedUntil, time.Now().UTC()) {
+		return database.User{}, auth.Claims{}, errSuspended
+	}
+
+	return user, claims, nil
+}
+
+// requireUser authenticates the caller of an endpoint that needs a logged
+// in user. It writes the error response itself and reports whether the
+// handler should continue.
+func (cfg *apiConfig) requireUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	user, err := cfg.authenticate(r)
+	if errors.Is(err, errSuspended) {
+		respondWithError(w, 403, "Account is suspended")
+		return uuid.Nil, false
+	}
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return uuid.Nil, false
+	}
+
+	return user.ID, true
+}
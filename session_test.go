@@ -0,0 +1,30 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIsSuspended(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		suspendedUntil sql.NullTime
+		expected       bool
+	}{
+		{suspendedUntil: sql.NullTime{}, expected: false},
+		{suspendedUntil: sql.NullTime{Time: now.Add(time.Hour), Valid: true}, expected: true},
+		{suspendedUntil: sql.NullTime{Time: now.Add(-time.Hour), Valid: true}, expected: false},
+		{suspendedUntil: sql.NullTime{Time: now, Valid: true}, expected: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if got := isSuspended(c.suspendedUntil, now); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
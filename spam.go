@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/spam"
+)
+
+// classifySpam runs the spam rules over a new chirp by user.
+func (cfg *apiConfig) classifySpam(ctx context.Context, user database.User, body string, now time.Time) (spam.Decision, error) {
+	rows, err := cfg.db.GetRecentChirpBodies(ctx, database.GetRecentChirpBodiesParams{
+		UserID: user.ID,
+		Since:  now.Add(-cfg.spam.Config().Lookback()),
+	})
+	if err != nil {
+		return spam.Decision{}, err
+	}
+
+	recent := make([]spam.RecentChirp, 0, len(rows))
+	for _, row := range rows {
+		recent = append(recent, spam.RecentChirp{Body: row.Body, CreatedAt: row.CreatedAt})
+	}
+
+	return cfg.spam.Classify(spam.Input{
+		Body:           body,
+		AuthorJoinedAt: user.CreatedAt,
+		Recent:         recent,
+		Now:            now,
+	}), nil
+}
+
+// logSpamDecision records d, with chirpID unset for rejected chirps.
+func logSpamDecision(ctx context.Context, q *database.Queries, userID uuid.UUID, chirpID uuid.NullUUID, d spam.Decision) error {
+	signals, err := json.Marshal(d.Signals)
+	if err != nil {
+		return err
+	}
+
+	return q.CreateSpamDecision(ctx, database.CreateSpamDecisionParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+		Outcome: string(d.Outcome),
+		Score:   d.Score,
+		Signals: signals,
+	})
+}
+
+// spamDetails summarizes the signals of d for a moderation report.
+func spamDetails(d spam.Decision) string {
+	details := make([]string, 0, len(d.Signals))
+	for _, s := range d.Signals {
+		details = append(details, s.Detail)
+	}
+	return strings.Join(details, "; ")
+}
+
+type spamDecisionVal struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UserID    uuid.UUID       `json:"user_id"`
+	ChirpID   *uuid.UUID      `json:"chirp_id"`
+	Outcome   string          `json:"outcome"`
+	Score     float64         `json:"score"`
+	Signals   json.RawMessage `json:"signals"`
+}
+
+func (cfg *apiConfig) HandleListSpamDecisions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	outcomes := []string{string(spam.Hold), string(spam.Reject)}
+	switch outcome := spam.Outcome(r.URL.Query().Get("outcome")); outcome {
+	case "":
+	case spam.Allow, spam.Hold, spam.Reject:
+		outcomes = []string{string(outcome)}
+	default:
+		respondWithError(w, 400, fmt.Sprintf("unknown outcome %q", outcome))
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	decisions, err := cfg.db.ListSpamDecisions(r.Context(), database.ListSpamDecisionsParams{
+		Outcomes:   outcomes,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]spamDecisionVal, 0, len(decisions))
+	for _, d := range decisions {
+		retVals = append(retVals, spamDecisionVal{
+			ID:        d.ID,
+			CreatedAt: d.CreatedAt,
+			UserID:    d.UserID,
+			ChirpID:   nullUUIDPtr(d.ChirpID),
+			Outcome:   d.Outcome,
+			Score:     d.Score,
+			Signals:   d.Signals,
+		})
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
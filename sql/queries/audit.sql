@@ -0,0 +1,37 @@
+-- name: LockAuditLog :exec
+-- Serializes appends for the rest of the transaction so that two writers
+-- cannot chain onto the same row.
+SELECT pg_advisory_xact_lock(hashtext('audit_events'));
+
+-- name: GetLastAuditEvent :one
+SELECT * FROM audit_events ORDER BY id DESC LIMIT 1;
+
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, kind, actor_id, target_id, ip, metadata, prev_hash, hash)
+VALUES (
+    sqlc.arg(id),
+    sqlc.arg(created_at),
+    sqlc.arg(kind),
+    sqlc.narg(actor_id),
+    sqlc.narg(target_id),
+    sqlc.arg(ip),
+    sqlc.arg(metadata),
+    sqlc.arg(prev_hash),
+    sqlc.arg(hash)
+);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg(kind)::TEXT IS NULL OR kind = sqlc.narg(kind))
+AND (sqlc.narg(actor_id)::UUID IS NULL OR actor_id = sqlc.narg(actor_id))
+AND (sqlc.narg(target_id)::UUID IS NULL OR target_id = sqlc.narg(target_id))
+AND (sqlc.narg(since)::TIMESTAMP IS NULL OR created_at >= sqlc.narg(since))
+AND (sqlc.narg(until)::TIMESTAMP IS NULL OR created_at < sqlc.narg(until))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: GetAuditEventsAfter :many
+SELECT * FROM audit_events
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
@@ -1,22 +1,134 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, status, publish_at, visibility, content_warning, sensitive)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
 )
 RETURNING *;
 
 -- name: GetAllChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+SELECT * FROM chirps AS c
+WHERE c.deleted_at IS NULL
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, sqlc.arg(viewer_id))
+AND (c.visibility IN ('public', 'followers') OR c.user_id = sqlc.arg(viewer_id))
+AND NOT EXISTS (
+    SELECT 1 FROM mutes AS m
+    WHERE m.muter_id = sqlc.arg(viewer_id) AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at ASC;
 
 -- name: GetChirpsByUserId :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+SELECT * FROM chirps AS c
+WHERE c.user_id = sqlc.arg(user_id)
+AND c.deleted_at IS NULL
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, sqlc.arg(viewer_id))
+AND NOT EXISTS (
+    SELECT 1 FROM mutes AS m
+    WHERE m.muter_id = sqlc.arg(viewer_id) AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at ASC;
 
 -- name: GetChirp :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT * FROM chirps WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: GetChirpForViewer :one
+-- Soft-deleted chirps are returned too so that callers can answer with a
+-- tombstone; getVisibleChirp turns them into errChirpDeleted.
+SELECT * FROM chirps AS c
+WHERE c.id = sqlc.arg(id)
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, sqlc.arg(viewer_id));
+
+-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = sqlc.arg(id)
+AND user_id = sqlc.arg(user_id)
+AND deleted_at > sqlc.arg(deleted_after)
+RETURNING *;
+
+-- name: PurgeDeletedChirps :many
+WITH purged AS (
+    DELETE FROM chirps
+    WHERE id IN (
+        SELECT id FROM chirps
+        WHERE deleted_at < sqlc.arg(deleted_before)
+        ORDER BY deleted_at
+        LIMIT sqlc.arg(batch_size)
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING id
+)
+SELECT p.id, m.blob_key, m.thumbnail_key
+FROM purged AS p
+LEFT JOIN chirp_media AS m ON m.chirp_id = p.id;
+
+-- name: UpdateChirpStatus :one
+UPDATE chirps
+SET status = sqlc.arg(status),
+    publish_at = sqlc.narg(publish_at),
+    created_at = CASE WHEN sqlc.arg(status)::TEXT = 'published' THEN NOW() ELSE created_at END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id) AND status <> 'published' AND deleted_at IS NULL
+RETURNING *;
+
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET status = 'published', created_at = publish_at, updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+    ORDER BY publish_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: HideChirp :exec
+UPDATE chirps SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: LiftAutomaticHide :execrows
+-- Unhides a chirp the system hid, for spam or too many reports. A hide a
+-- moderator placed or confirmed with hide_chirp stays.
+UPDATE chirps SET hidden_at = NULL
+WHERE id = $1
+AND hidden_at IS NOT NULL
+AND NOT EXISTS (
+    SELECT 1 FROM moderation_log AS l
+    WHERE l.chirp_id = chirps.id
+    AND l.action = 'hide_chirp'
+    AND l.created_at >= chirps.hidden_at
+);
+
+-- name: SetChirpSensitivity :one
+UPDATE chirps
+SET content_warning = sqlc.arg(content_warning), sensitive = sqlc.arg(sensitive), updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
 
--- name: DeleteChirp :exec
-DELETE FROM chirps WHERE id = $1;
\ No newline at end of file
+-- name: HideReportedChirp :execrows
+-- Hides the chirp once enough users have open reports against it. System
+-- reports and reports by shadow-banned users do not count.
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = sqlc.arg(id)
+AND hidden_at IS NULL
+AND (
+    SELECT COUNT(*) FROM reports AS r
+    INNER JOIN users AS u ON u.id = r.reporter_id
+    WHERE r.chirp_id = sqlc.arg(id)
+    AND r.status <> 'resolved'
+    AND NOT u.shadow_banned
+) >= sqlc.arg(threshold)::INT;
@@ -0,0 +1,103 @@
+-- name: CreateConversation :one
+-- Returns no rows when the one-to-one conversation already exists.
+INSERT INTO conversations (id, direct_key, created_at, updated_at)
+VALUES (gen_random_uuid(), sqlc.narg(direct_key), NOW(), NOW())
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations WHERE direct_key = $1;
+
+-- name: AddConversationParticipants :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+SELECT sqlc.arg(conversation_id), unnest(sqlc.arg(user_ids)::UUID[]), NOW();
+
+-- name: IsConversationParticipant :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: GetConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::UUID[])
+ORDER BY joined_at, user_id;
+
+-- name: ListConversations :many
+-- unread_count leaves out the user's own messages and those of users they
+-- blocked or who are shadow-banned. conversation_id narrows the list down
+-- to one conversation.
+SELECT
+    c.id,
+    c.direct_key,
+    c.created_at,
+    c.last_message_at,
+    (
+        SELECT COUNT(*) FROM direct_messages AS m
+        WHERE m.conversation_id = c.id
+          AND m.sender_id IS DISTINCT FROM p.user_id
+          AND (p.last_read_at IS NULL OR m.created_at > p.last_read_at)
+          AND NOT EXISTS (
+              SELECT 1 FROM blocks AS b WHERE b.blocker_id = p.user_id AND b.blocked_id = m.sender_id
+          )
+          AND NOT EXISTS (
+              SELECT 1 FROM users AS u WHERE u.id = m.sender_id AND u.shadow_banned
+          )
+    ) AS unread_count
+FROM conversations AS c
+INNER JOIN conversation_participants AS p ON p.conversation_id = c.id
+WHERE p.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(conversation_id)::UUID IS NULL OR c.id = sqlc.narg(conversation_id)::UUID)
+ORDER BY COALESCE(c.last_message_at, c.created_at) DESC, c.id
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: IsBlockedWithAny :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::UUID[]))
+       OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::UUID[]))
+);
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, conversation_id, sender_id, created_at, key_id, nonce, ciphertext)
+VALUES ($1, $2, $3, NOW(), $4, $5, $6)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET last_message_at = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetDirectMessage :one
+SELECT * FROM direct_messages WHERE id = $1 AND conversation_id = $2;
+
+-- name: ListDirectMessages :many
+-- Newest first. Messages older than before_id page back through the
+-- conversation. Messages of senders the viewer blocked are left out, and
+-- those of shadow-banned senders are only shown to the senders themselves.
+SELECT m.* FROM direct_messages AS m
+WHERE m.conversation_id = sqlc.arg(conversation_id)
+  AND (
+      sqlc.narg(before_id)::UUID IS NULL
+      OR (m.created_at, m.id) < (
+          SELECT created_at, id FROM direct_messages
+          WHERE id = sqlc.narg(before_id)::UUID AND conversation_id = sqlc.arg(conversation_id)
+      )
+  )
+  AND NOT EXISTS (
+      SELECT 1 FROM blocks AS b WHERE b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = m.sender_id
+  )
+  AND (
+      m.sender_id = sqlc.arg(viewer_id)
+      OR NOT EXISTS (SELECT 1 FROM users AS u WHERE u.id = m.sender_id AND u.shadow_banned)
+  )
+ORDER BY m.created_at DESC, m.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: MarkConversationRead :exec
+-- Read receipts only move forward.
+UPDATE conversation_participants
+SET last_read_at = sqlc.arg(read_at)
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND user_id = sqlc.arg(user_id)
+  AND (last_read_at IS NULL OR last_read_at < sqlc.arg(read_at));
@@ -0,0 +1,29 @@
+-- name: GetActiveGrantCapabilities :many
+SELECT capability FROM entitlement_grants
+WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > NOW());
+
+-- name: ListEntitlementGrants :many
+SELECT * FROM entitlement_grants
+WHERE user_id = $1
+ORDER BY capability ASC;
+
+-- name: UpsertEntitlementGrant :one
+INSERT INTO entitlement_grants (user_id, capability, created_at, granted_by, reason, expires_at)
+VALUES (
+    sqlc.arg(user_id),
+    sqlc.arg(capability),
+    NOW(),
+    sqlc.narg(granted_by),
+    sqlc.arg(reason),
+    sqlc.narg(expires_at)
+)
+ON CONFLICT (user_id, capability) DO UPDATE
+SET created_at = NOW(),
+    granted_by = EXCLUDED.granted_by,
+    reason = EXCLUDED.reason,
+    expires_at = EXCLUDED.expires_at
+RETURNING *;
+
+-- name: DeleteEntitlementGrant :execrows
+DELETE FROM entitlement_grants
+WHERE user_id = $1 AND capability = $2;
@@ -0,0 +1,56 @@
+-- name: EnqueueJob :execrows
+INSERT INTO jobs (id, type, payload, max_attempts, run_at, unique_key, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg(type),
+    sqlc.arg(payload),
+    sqlc.arg(max_attempts),
+    sqlc.arg(run_at),
+    sqlc.narg(unique_key),
+    NOW(),
+    NOW()
+)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJobs :many
+-- Claims due jobs of one type, and running jobs whose lease expired.
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)),
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE jobs.type = sqlc.arg(type)
+    AND ((status = 'pending' AND run_at <= NOW()) OR (status = 'running' AND locked_until < NOW()))
+    ORDER BY run_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'done', locked_until = NULL, last_error = '', finished_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_until = NULL, last_error = sqlc.arg(last_error),
+    run_at = sqlc.arg(run_at), updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: FailJob :exec
+UPDATE jobs
+SET status = 'failed', locked_until = NULL, last_error = sqlc.arg(last_error),
+    finished_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: GetJobStats :many
+SELECT type, status, COUNT(*) AS count, MIN(run_at)::TIMESTAMP AS oldest_run_at
+FROM jobs
+GROUP BY type, status
+ORDER BY type, status;
+
+-- name: DeleteFinishedJobs :execrows
+DELETE FROM jobs WHERE status = 'done' AND finished_at < $1;
@@ -0,0 +1,37 @@
+-- name: CreateChirpMedia :one
+INSERT INTO chirp_media (
+    id,
+    created_at,
+    user_id,
+    content_type,
+    blob_key,
+    thumbnail_key,
+    thumbnail_content_type,
+    width,
+    height,
+    size_bytes
+) VALUES (
+    $1, NOW(), $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetChirpMedia :one
+SELECT * FROM chirp_media WHERE id = $1;
+
+-- name: AttachMediaToChirp :execrows
+UPDATE chirp_media
+SET chirp_id = sqlc.arg(chirp_id),
+    position = ARRAY_POSITION(sqlc.arg(ids)::UUID[], id) - 1
+WHERE id = ANY(sqlc.arg(ids)::UUID[])
+AND user_id = sqlc.arg(user_id)
+AND chirp_id IS NULL;
+
+-- name: GetMediaForChirps :many
+SELECT * FROM chirp_media
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+ORDER BY chirp_id, position;
+
+-- name: DeleteOrphanMedia :many
+DELETE FROM chirp_media
+WHERE chirp_id IS NULL AND created_at < $1
+RETURNING blob_key, thumbnail_key;
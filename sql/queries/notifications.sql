@@ -0,0 +1,65 @@
+-- name: CreateNotification :execrows
+-- Does nothing when the user turned the type off or the actor is
+-- shadow-banned.
+INSERT INTO notifications (id, user_id, type, group_key, chirp_id, actor_ids, actor_count, details, created_at, updated_at)
+SELECT
+    gen_random_uuid(),
+    sqlc.arg(user_id),
+    sqlc.arg(type),
+    sqlc.arg(group_key),
+    sqlc.narg(chirp_id),
+    CASE WHEN sqlc.narg(actor_id)::UUID IS NULL THEN '{}'::UUID[] ELSE ARRAY[sqlc.narg(actor_id)::UUID] END,
+    CASE WHEN sqlc.narg(actor_id)::UUID IS NULL THEN 0 ELSE 1 END,
+    sqlc.arg(details),
+    NOW(),
+    NOW()
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences AS p
+    WHERE p.user_id = sqlc.arg(user_id) AND p.type = sqlc.arg(type) AND NOT p.enabled
+)
+AND NOT EXISTS (
+    SELECT 1 FROM users AS u
+    WHERE u.id = sqlc.narg(actor_id)::UUID AND u.shadow_banned
+)
+ON CONFLICT (user_id, type, group_key) WHERE read_at IS NULL DO UPDATE
+SET actor_ids = CASE
+        WHEN cardinality(EXCLUDED.actor_ids) = 0 OR EXCLUDED.actor_ids[1] = ANY(notifications.actor_ids)
+        THEN notifications.actor_ids
+        ELSE (EXCLUDED.actor_ids || notifications.actor_ids)[1:5]
+    END,
+    actor_count = notifications.actor_count + CASE
+        WHEN cardinality(EXCLUDED.actor_ids) = 0 OR EXCLUDED.actor_ids[1] = ANY(notifications.actor_ids)
+        THEN 0
+        ELSE 1
+    END,
+    details = EXCLUDED.details,
+    updated_at = NOW();
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id) AND (NOT sqlc.arg(unread_only)::BOOLEAN OR read_at IS NULL)
+ORDER BY updated_at DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+-- Leaves notifications that changed after the given time unread, so a
+-- client only clears what it has shown.
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL AND updated_at <= $2;
+
+-- name: GetNotificationPreferences :many
+SELECT * FROM notification_preferences WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled;
@@ -0,0 +1,36 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, closes_at)
+VALUES (gen_random_uuid(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (id, poll_id, position, label)
+VALUES (gen_random_uuid(), $1, $2, $3);
+
+-- name: GetPollByChirp :one
+SELECT * FROM polls WHERE chirp_id = $1;
+
+-- name: GetPollsForChirps :many
+SELECT * FROM polls WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[]);
+
+-- name: GetPollOptionsWithVotes :many
+SELECT o.id, o.poll_id, o.position, o.label, COUNT(v.user_id) AS votes
+FROM poll_options AS o
+LEFT JOIN poll_votes AS v ON v.option_id = o.id
+WHERE o.poll_id = ANY(sqlc.arg(poll_ids)::UUID[])
+GROUP BY o.id
+ORDER BY o.poll_id, o.position;
+
+-- name: GetUserPollVotes :many
+SELECT poll_id, option_id FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND poll_id = ANY(sqlc.arg(poll_ids)::UUID[]);
+
+-- name: CastPollVote :execrows
+INSERT INTO poll_votes (poll_id, user_id, option_id, created_at)
+SELECT o.poll_id, sqlc.arg(user_id), o.id, NOW()
+FROM poll_options AS o
+INNER JOIN polls AS p ON p.id = o.poll_id
+WHERE o.id = sqlc.arg(option_id)
+AND p.id = sqlc.arg(poll_id)
+AND p.closes_at > NOW()
+ON CONFLICT (poll_id, user_id) DO NOTHING;
@@ -0,0 +1,17 @@
+-- name: ListProfaneTerms :many
+SELECT * FROM profane_terms ORDER BY LOWER(term);
+
+-- name: CreateProfaneTerm :one
+INSERT INTO profane_terms (id, created_at, updated_at, term, action)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: UpdateProfaneTerm :one
+UPDATE profane_terms
+SET term = $1, action = $2, updated_at = NOW()
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteProfaneTerm :execrows
+DELETE FROM profane_terms WHERE id = $1;
+
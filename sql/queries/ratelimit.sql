@@ -0,0 +1,19 @@
+-- name: TakeRateLimitToken :one
+-- Refills the bucket for the time since its last use and takes a token if
+-- one is available, all in a single statement so concurrent requests on
+-- several instances cannot overdraw it. last_allowed records whether this
+-- call got a token.
+INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at, last_allowed)
+VALUES (sqlc.arg(key), sqlc.arg(burst)::FLOAT8 - 1, NOW(), TRUE)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN LEAST(sqlc.arg(burst)::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * sqlc.arg(rate)::FLOAT8) >= 1
+        THEN LEAST(sqlc.arg(burst)::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * sqlc.arg(rate)::FLOAT8) - 1
+        ELSE LEAST(sqlc.arg(burst)::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * sqlc.arg(rate)::FLOAT8)
+    END,
+    last_allowed = LEAST(sqlc.arg(burst)::FLOAT8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at)::FLOAT8 * sqlc.arg(rate)::FLOAT8) >= 1,
+    updated_at = NOW()
+RETURNING tokens, last_allowed;
+
+-- name: DeleteIdleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets WHERE updated_at < $1;
@@ -0,0 +1,61 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.narg(reporter_id),
+    sqlc.arg(user_id),
+    sqlc.narg(chirp_id),
+    sqlc.arg(reason),
+    sqlc.arg(details)
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: ListReports :many
+SELECT * FROM reports
+WHERE status = ANY(sqlc.arg(statuses)::TEXT[])
+ORDER BY created_at ASC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed', claimed_by = sqlc.arg(moderator_id), claimed_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg(id)
+AND (status = 'open' OR (status = 'claimed' AND claimed_by = sqlc.arg(moderator_id)))
+RETURNING *;
+
+-- name: ResolveReports :many
+-- Resolves every unresolved report against the same target, so a chirp or
+-- user is reviewed once no matter how many people reported it.
+UPDATE reports
+SET status = 'resolved',
+    resolved_by = sqlc.arg(moderator_id),
+    resolved_at = NOW(),
+    resolution = sqlc.arg(resolution),
+    updated_at = NOW()
+WHERE status <> 'resolved'
+AND user_id = sqlc.arg(user_id)
+AND chirp_id IS NOT DISTINCT FROM sqlc.narg(chirp_id)
+RETURNING *;
+
+-- name: CreateModerationLog :exec
+INSERT INTO moderation_log (id, created_at, moderator_id, action, report_id, user_id, chirp_id, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.narg(moderator_id),
+    sqlc.arg(action),
+    sqlc.narg(report_id),
+    sqlc.narg(user_id),
+    sqlc.narg(chirp_id),
+    sqlc.arg(note)
+);
+
+-- name: ListModerationLog :many
+SELECT * FROM moderation_log
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
@@ -0,0 +1,24 @@
+-- name: GetRecentChirpBodies :many
+-- Soft-deleted chirps are included so that deleting a burst does not make
+-- room for the next one.
+SELECT body, created_at FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND created_at >= sqlc.arg(since)
+ORDER BY created_at DESC;
+
+-- name: CreateSpamDecision :exec
+INSERT INTO spam_decisions (id, created_at, user_id, chirp_id, outcome, score, signals)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg(user_id),
+    sqlc.narg(chirp_id),
+    sqlc.arg(outcome),
+    sqlc.arg(score),
+    sqlc.arg(signals)
+);
+
+-- name: ListSpamDecisions :many
+SELECT * FROM spam_decisions
+WHERE outcome = ANY(sqlc.arg(outcomes)::TEXT[])
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
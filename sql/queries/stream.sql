@@ -0,0 +1,34 @@
+-- name: LockStreamEvents :exec
+-- Serializes inserts for the rest of the transaction, so ids become
+-- visible in order and a reader that saw id N never misses a smaller one.
+SELECT pg_advisory_xact_lock(hashtext('stream_events'));
+
+-- name: CreateStreamEvent :exec
+INSERT INTO stream_events (topics, type, data)
+VALUES (sqlc.arg(topics)::TEXT[], sqlc.arg(type), sqlc.arg(data));
+
+-- name: GetLatestStreamEventID :one
+SELECT COALESCE(MAX(id), 0)::BIGINT FROM stream_events;
+
+-- name: GetStreamEventsAfter :many
+SELECT * FROM stream_events
+WHERE id > sqlc.arg(after_id)
+ORDER BY id ASC
+LIMIT sqlc.arg(batch_size);
+
+-- name: GetTopicStreamEventsAfter :many
+SELECT * FROM stream_events
+WHERE id > sqlc.arg(after_id) AND topics && sqlc.arg(topics)::TEXT[]
+ORDER BY id ASC
+LIMIT sqlc.arg(batch_size);
+
+-- name: DeleteStreamEventsBefore :execrows
+DELETE FROM stream_events WHERE created_at < $1;
+
+-- name: GetStreamFilteredAuthors :many
+-- Authors whose chirps the viewer does not see: blocks either way and mutes.
+SELECT blocked_id AS user_id FROM blocks WHERE blocker_id = sqlc.arg(viewer_id)
+UNION
+SELECT blocker_id FROM blocks WHERE blocked_id = sqlc.arg(viewer_id)
+UNION
+SELECT muted_id FROM mutes WHERE muter_id = sqlc.arg(viewer_id);
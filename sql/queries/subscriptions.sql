@@ -0,0 +1,28 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: GetSubscriptionForUpdate :one
+SELECT * FROM subscriptions WHERE user_id = $1 FOR UPDATE;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end, grace_until)
+VALUES (
+    sqlc.arg(user_id),
+    NOW(),
+    NOW(),
+    sqlc.arg(status),
+    sqlc.arg(current_period_end),
+    sqlc.arg(grace_until)
+)
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    grace_until = EXCLUDED.grace_until,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status <> 'expired' AND grace_until <= NOW()
+RETURNING user_id;
@@ -17,4 +17,8 @@ WHERE token = $1;
 -- name: GetUserFromRefreshToken :one
 SELECT * FROM refresh_tokens AS rt
 INNER JOIN users AS u ON rt.user_id = u.id
-WHERE rt.token = $1;
\ No newline at end of file
+WHERE rt.token = $1;
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,77 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, user_id, created_at, updated_at, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg(user_id),
+    NOW(),
+    NOW(),
+    sqlc.arg(url),
+    sqlc.arg(secret),
+    sqlc.arg(events)::TEXT[]
+)
+RETURNING *;
+
+-- name: CountWebhookEndpoints :one
+SELECT COUNT(*) FROM webhook_endpoints WHERE user_id = $1;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :exec
+-- Queues one delivery for each endpoint of the user that subscribed to
+-- the event type.
+INSERT INTO webhook_deliveries (id, endpoint_id, created_at, event_type, payload, next_attempt_at)
+SELECT gen_random_uuid(), e.id, NOW(), sqlc.arg(event_type), sqlc.arg(payload), NOW()
+FROM webhook_endpoints AS e
+WHERE e.user_id = sqlc.arg(user_id) AND sqlc.arg(event_type) = ANY(e.events);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Pushes next_attempt_at out by the lease so that other instances skip
+-- the claimed rows until this one has reported the result.
+UPDATE webhook_deliveries AS d
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg(lease_seconds))
+FROM webhook_endpoints AS e
+WHERE e.id = d.endpoint_id
+AND d.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at ASC
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING d.id, d.event_type, d.payload, d.attempts, e.url, e.secret;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_status_code = sqlc.narg(status_code),
+    last_error = '', delivered_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: MarkWebhookDeliveryFailed :exec
+-- A failed attempt is retried at next_attempt_at, unless dead is set.
+UPDATE webhook_deliveries
+SET status = CASE WHEN sqlc.arg(dead)::BOOLEAN THEN 'dead' ELSE 'pending' END,
+    attempts = attempts + 1,
+    last_status_code = sqlc.narg(status_code),
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = sqlc.arg(next_attempt_at)
+WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: RetryWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND endpoint_id = $2 AND status = 'dead';
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -26,7 +27,42 @@ WHERE email = $1;
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
 
--- name: UpdateUserRed :exec
+-- name: GetUserByHandle :one
+SELECT * FROM users WHERE LOWER(handle) = LOWER(sqlc.arg(handle));
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET handle = $1, display_name = $2, bio = $3, avatar_url = $4, updated_at = NOW()
+WHERE id = $5
+RETURNING *;
+
+-- name: GetUserSummaries :many
+SELECT id, handle, display_name, avatar_url
+FROM users
+WHERE id = ANY(sqlc.arg(ids)::UUID[]);
+
+-- name: SuspendUser :exec
 UPDATE users
-SET is_chirpy_red = $1
-WHERE id = $2;
\ No newline at end of file
+SET suspended_until = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: SetUserShadowBanned :exec
+UPDATE users
+SET shadow_banned = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: SetUserExpandSensitive :one
+UPDATE users
+SET expand_sensitive = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: GetMentionedUserIDs :many
+-- Resolves the handles a chirp mentions to the users who can see it, which
+-- leaves out the author, users on either side of a block with them and,
+-- for followers-only chirps, users who do not follow them.
+SELECT u.id FROM users AS u
+INNER JOIN chirps AS c ON c.id = sqlc.arg(chirp_id)
+WHERE LOWER(u.handle) = ANY(sqlc.arg(handles)::TEXT[])
+AND u.id <> c.user_id
+AND chirp_visible_to(c.user_id, c.status, c.visibility, c.hidden_at, u.id);
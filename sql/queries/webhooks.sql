@@ -0,0 +1,45 @@
+-- name: CreateWebhookEvent :one
+-- Returns no row when the event has been received before.
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at)
+VALUES (
+    gen_random_uuid(),
+    sqlc.arg(provider),
+    sqlc.arg(event_id),
+    sqlc.arg(event_type),
+    sqlc.arg(payload),
+    NOW()
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events WHERE id = $1;
+
+-- name: LockWebhookEvent :one
+-- Only events that still need processing are returned. The row lock keeps
+-- a concurrent retry from applying the same event twice.
+SELECT * FROM webhook_events
+WHERE id = $1 AND status IN ('pending', 'failed')
+FOR UPDATE;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events
+SET status = sqlc.arg(status), attempts = attempts + 1, last_error = '', processed_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: FailWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'failed', attempts = attempts + 1, last_error = sqlc.arg(last_error)
+WHERE id = sqlc.arg(id);
+
+-- name: RequeueWebhookEvent :one
+UPDATE webhook_events
+SET status = 'pending', processed_at = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE status = ANY(sqlc.arg(statuses)::TEXT[])
+ORDER BY received_at DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
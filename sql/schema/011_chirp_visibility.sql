@@ -0,0 +1,46 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+
+ALTER TABLE chirps ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'unlisted', 'private'));
+
+-- chirp_visible_to is the single read authorization check for chirps. Every
+-- query that returns chirps to a viewer must go through it. Anonymous
+-- viewers are passed as the nil UUID, which matches no follow or block.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(chirp_author UUID, chirp_status TEXT, chirp_visibility TEXT, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT chirp_author = viewer OR (
+        chirp_status = 'published'
+        AND NOT EXISTS (
+            SELECT 1 FROM blocks AS b
+            WHERE (b.blocker_id = viewer AND b.blocked_id = chirp_author)
+               OR (b.blocker_id = chirp_author AND b.blocked_id = viewer)
+        )
+        AND CASE chirp_visibility
+            WHEN 'public' THEN TRUE
+            WHEN 'unlisted' THEN TRUE
+            WHEN 'followers' THEN EXISTS (
+                SELECT 1 FROM follows AS f
+                WHERE f.follower_id = viewer AND f.followee_id = chirp_author
+            )
+            ELSE FALSE
+        END
+    )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible_to(UUID, TEXT, TEXT, UUID);
+ALTER TABLE chirps DROP COLUMN visibility;
+DROP TABLE follows;
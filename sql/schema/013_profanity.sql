@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'admin'));
+
+CREATE TABLE profane_terms (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    term TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag'))
+);
+
+CREATE UNIQUE INDEX profane_terms_term_idx ON profane_terms (LOWER(term));
+
+INSERT INTO profane_terms (id, term, action) VALUES
+    (gen_random_uuid(), 'kerfuffle', 'mask'),
+    (gen_random_uuid(), 'sharbert', 'mask'),
+    (gen_random_uuid(), 'fornax', 'mask');
+
+-- chirp_flags holds chirps that matched a term with the flag action, until
+-- someone reviews them.
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    terms TEXT[] NOT NULL
+);
+
+CREATE INDEX chirp_flags_chirp_id_idx ON chirp_flags (chirp_id);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE profane_terms;
+ALTER TABLE users DROP COLUMN role;
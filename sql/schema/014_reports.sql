@@ -0,0 +1,146 @@
+-- +goose Up
+ALTER TABLE users DROP CONSTRAINT users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP;
+
+-- A report targets a user, and a chirp of that user when chirp_id is set.
+-- Reports without a reporter are raised by the system, for example when a
+-- chirp matches a profane term with the flag action.
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reporter_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN (
+        'spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm',
+        'impersonation', 'other', 'flagged_terms'
+    )),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved')),
+    claimed_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolved_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    resolution TEXT CHECK (resolution IN ('dismiss', 'hide_chirp', 'suspend_user'))
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+CREATE INDEX reports_chirp_id_idx ON reports (chirp_id);
+CREATE INDEX reports_user_id_idx ON reports (user_id);
+
+-- One report per reporter and target, so repeated reports cannot push a
+-- chirp over the auto-hide threshold.
+CREATE UNIQUE INDEX reports_reporter_chirp_idx ON reports (reporter_id, chirp_id)
+    WHERE chirp_id IS NOT NULL;
+CREATE UNIQUE INDEX reports_reporter_user_idx ON reports (reporter_id, user_id)
+    WHERE chirp_id IS NULL;
+
+INSERT INTO reports (id, created_at, updated_at, user_id, chirp_id, reason, details)
+SELECT f.id, f.created_at, f.created_at, c.user_id, f.chirp_id, 'flagged_terms', ARRAY_TO_STRING(f.terms, ', ')
+FROM chirp_flags AS f
+INNER JOIN chirps AS c ON c.id = f.chirp_id;
+
+DROP TABLE chirp_flags;
+
+-- moderation_log records every moderation action. A NULL moderator_id
+-- means the system acted on its own, e.g. when auto-hiding a chirp.
+CREATE TABLE moderation_log (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    moderator_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    report_id UUID REFERENCES reports (id) ON DELETE SET NULL,
+    user_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_log_created_at_idx ON moderation_log (created_at);
+
+-- Hidden chirps stay visible to their author only.
+DROP FUNCTION chirp_visible_to(UUID, TEXT, TEXT, UUID);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(chirp_author UUID, chirp_status TEXT, chirp_visibility TEXT, chirp_hidden_at TIMESTAMP, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT chirp_author = viewer OR (
+        chirp_status = 'published'
+        AND chirp_hidden_at IS NULL
+        AND NOT EXISTS (
+            SELECT 1 FROM blocks AS b
+            WHERE (b.blocker_id = viewer AND b.blocked_id = chirp_author)
+               OR (b.blocker_id = chirp_author AND b.blocked_id = viewer)
+        )
+        AND CASE chirp_visibility
+            WHEN 'public' THEN TRUE
+            WHEN 'unlisted' THEN TRUE
+            WHEN 'followers' THEN EXISTS (
+                SELECT 1 FROM follows AS f
+                WHERE f.follower_id = viewer AND f.followee_id = chirp_author
+            )
+            ELSE FALSE
+        END
+    )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION chirp_visible_to(UUID, TEXT, TEXT, TIMESTAMP, UUID);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(chirp_author UUID, chirp_status TEXT, chirp_visibility TEXT, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT chirp_author = viewer OR (
+        chirp_status = 'published'
+        AND NOT EXISTS (
+            SELECT 1 FROM blocks AS b
+            WHERE (b.blocker_id = viewer AND b.blocked_id = chirp_author)
+               OR (b.blocker_id = chirp_author AND b.blocked_id = viewer)
+        )
+        AND CASE chirp_visibility
+            WHEN 'public' THEN TRUE
+            WHEN 'unlisted' THEN TRUE
+            WHEN 'followers' THEN EXISTS (
+                SELECT 1 FROM follows AS f
+                WHERE f.follower_id = viewer AND f.followee_id = chirp_author
+            )
+            ELSE FALSE
+        END
+    )
+$$;
+-- +goose StatementEnd
+
+DROP TABLE moderation_log;
+
+CREATE TABLE chirp_flags (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    chirp_id UUID NOT NULL REFERENCES chirps (id) ON DELETE CASCADE,
+    terms TEXT[] NOT NULL
+);
+
+CREATE INDEX chirp_flags_chirp_id_idx ON chirp_flags (chirp_id);
+
+INSERT INTO chirp_flags (id, created_at, chirp_id, terms)
+SELECT id, created_at, chirp_id, STRING_TO_ARRAY(details, ', ')
+FROM reports
+WHERE reason = 'flagged_terms' AND chirp_id IS NOT NULL;
+
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN suspended_until;
+
+UPDATE users SET role = 'user' WHERE role = 'moderator';
+ALTER TABLE users DROP CONSTRAINT users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check
+    CHECK (role IN ('user', 'admin'));
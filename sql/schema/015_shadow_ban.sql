@@ -0,0 +1,62 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN shadow_banned BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Chirps of shadow-banned users are visible to their author only.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_visible_to(chirp_author UUID, chirp_status TEXT, chirp_visibility TEXT, chirp_hidden_at TIMESTAMP, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT chirp_author = viewer OR (
+        chirp_status = 'published'
+        AND chirp_hidden_at IS NULL
+        AND NOT EXISTS (
+            SELECT 1 FROM users AS u
+            WHERE u.id = chirp_author AND u.shadow_banned
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM blocks AS b
+            WHERE (b.blocker_id = viewer AND b.blocked_id = chirp_author)
+               OR (b.blocker_id = chirp_author AND b.blocked_id = viewer)
+        )
+        AND CASE chirp_visibility
+            WHEN 'public' THEN TRUE
+            WHEN 'unlisted' THEN TRUE
+            WHEN 'followers' THEN EXISTS (
+                SELECT 1 FROM follows AS f
+                WHERE f.follower_id = viewer AND f.followee_id = chirp_author
+            )
+            ELSE FALSE
+        END
+    )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION chirp_visible_to(chirp_author UUID, chirp_status TEXT, chirp_visibility TEXT, chirp_hidden_at TIMESTAMP, viewer UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+    SELECT chirp_author = viewer OR (
+        chirp_status = 'published'
+        AND chirp_hidden_at IS NULL
+        AND NOT EXISTS (
+            SELECT 1 FROM blocks AS b
+            WHERE (b.blocker_id = viewer AND b.blocked_id = chirp_author)
+               OR (b.blocker_id = chirp_author AND b.blocked_id = viewer)
+        )
+        AND CASE chirp_visibility
+            WHEN 'public' THEN TRUE
+            WHEN 'unlisted' THEN TRUE
+            WHEN 'followers' THEN EXISTS (
+                SELECT 1 FROM follows AS f
+                WHERE f.follower_id = viewer AND f.followee_id = chirp_author
+            )
+            ELSE FALSE
+        END
+    )
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE users DROP COLUMN shadow_banned;
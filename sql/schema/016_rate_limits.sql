@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    last_allowed BOOLEAN NOT NULL
+);
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Every spam check of a new chirp is logged, including the ones that
+-- were allowed, so the rules can be tuned against real traffic. chirp_id
+-- is NULL when the chirp was rejected.
+CREATE TABLE spam_decisions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE SET NULL,
+    outcome TEXT NOT NULL CHECK (outcome IN ('allow', 'hold', 'reject')),
+    score DOUBLE PRECISION NOT NULL,
+    signals JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX spam_decisions_created_at_idx ON spam_decisions (created_at);
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+DROP TABLE spam_decisions;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- audit_events is append-only. Each row carries the hash of the row
+-- before it, so editing or removing a row breaks the chain from that row
+-- on. IDs are assigned by the writer, not a sequence, so gaps are
+-- detectable too. There are no foreign keys because events must outlive
+-- the users they mention.
+CREATE TABLE audit_events (
+    id BIGINT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    actor_id UUID,
+    target_id UUID,
+    ip TEXT NOT NULL DEFAULT '',
+    -- JSON rather than JSONB keeps the exact text that was hashed.
+    metadata JSON NOT NULL,
+    prev_hash BYTEA NOT NULL,
+    hash BYTEA NOT NULL
+);
+
+CREATE INDEX audit_events_kind_idx ON audit_events (kind, id);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id, id);
+CREATE INDEX audit_events_target_id_idx ON audit_events (target_id, id);
+
+-- +goose StatementBegin
+CREATE FUNCTION audit_events_append_only()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_events_append_only
+BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
+
+-- +goose Down
+DROP TABLE audit_events;
+DROP FUNCTION audit_events_append_only();
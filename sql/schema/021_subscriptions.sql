@@ -0,0 +1,46 @@
+-- +goose Up
+-- A user has at most one Chirpy Red subscription. Perks last until
+-- grace_until: the end of the paid period plus a grace period while it is
+-- active or past due, the end of the paid period once canceled.
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    plan TEXT NOT NULL DEFAULT 'red',
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_end TIMESTAMP NOT NULL,
+    grace_until TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_grace_until_idx ON subscriptions (grace_until)
+    WHERE status <> 'expired';
+
+-- users.is_chirpy_red is kept as a cache of the subscription state so
+-- existing reads stay cheap. Only this trigger writes it.
+-- +goose StatementBegin
+CREATE FUNCTION sync_chirpy_red()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE users
+    SET is_chirpy_red = (NEW.status <> 'expired' AND NEW.grace_until > NOW()),
+        updated_at = NOW()
+    WHERE id = NEW.user_id;
+    RETURN NEW;
+END;
+$$;
+-- +goose StatementEnd
+
+CREATE TRIGGER subscriptions_sync_chirpy_red
+AFTER INSERT OR UPDATE ON subscriptions
+FOR EACH ROW EXECUTE FUNCTION sync_chirpy_red();
+
+-- Upgrades so far never expired. They carry over as subscriptions that
+-- run until a downgrade or refund arrives.
+INSERT INTO subscriptions (user_id, status, current_period_end, grace_until)
+SELECT id, 'active', '9999-12-31', '9999-12-31' FROM users WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
+DROP FUNCTION sync_chirpy_red();
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Capabilities granted to one user on top of their plan, usually by
+-- support. A grant without expires_at lasts until it is revoked.
+CREATE TABLE entitlement_grants (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    capability TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    granted_by UUID REFERENCES users (id) ON DELETE SET NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    PRIMARY KEY (user_id, capability)
+);
+
+-- +goose Down
+DROP TABLE entitlement_grants;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    -- A running job whose lease ran out is claimed again, so the work of a
+    -- crashed instance is not lost.
+    locked_until TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    -- Scheduled jobs use one key per slot so that only one instance
+    -- enqueues each run.
+    unique_key TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX jobs_claim_idx ON jobs (type, run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX jobs_finished_at_idx ON jobs (finished_at) WHERE status = 'done';
+
+-- +goose Down
+DROP TABLE jobs;
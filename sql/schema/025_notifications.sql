@@ -0,0 +1,33 @@
+-- +goose Up
+-- Repeated notifications are grouped: while a notification is unread, the
+-- next one with the same type and group key adds its actor to it instead
+-- of creating a new row. actor_ids keeps the most recent few actors.
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    group_key TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps (id) ON DELETE CASCADE,
+    actor_ids UUID[] NOT NULL DEFAULT '{}',
+    actor_count INTEGER NOT NULL DEFAULT 0,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX notifications_unread_group_idx ON notifications (user_id, type, group_key)
+    WHERE read_at IS NULL;
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, updated_at DESC);
+
+-- Rows only exist for types a user turned off or back on.
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- stream_events backs GET /api/stream. Each insert notifies the
+-- chirpy_stream channel with the new id so that every instance can relay
+-- the event to its own subscribers, and clients resume after a reconnect
+-- by asking for the events after the last id they saw.
+CREATE TABLE stream_events (
+    id BIGSERIAL PRIMARY KEY,
+    topics TEXT[] NOT NULL,
+    type TEXT NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX stream_events_created_at_idx ON stream_events (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_stream_event()
+RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    PERFORM pg_notify('chirpy_stream', NEW.id::TEXT);
+    RETURN NULL;
+END;
+$$;
+-- +goose StatementEnd
+
+CREATE TRIGGER notify_stream_event
+AFTER INSERT ON stream_events
+FOR EACH ROW EXECUTE FUNCTION notify_stream_event();
+
+-- +goose Down
+DROP TABLE stream_events;
+DROP FUNCTION notify_stream_event();
@@ -0,0 +1,41 @@
+-- +goose Up
+-- direct_key is set for one-to-one conversations to the two participant
+-- IDs in order, so that a pair of users only ever has one of them.
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    direct_key TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    last_message_at TIMESTAMP
+);
+
+-- last_read_at is when the last message the participant read was sent.
+CREATE TABLE conversation_participants (
+    conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    joined_at TIMESTAMP NOT NULL,
+    last_read_at TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants (user_id);
+
+-- Bodies are sealed with AES-GCM under the application key key_id. The
+-- conversation and message IDs are authenticated along with them, so a
+-- body cannot be moved to another row.
+CREATE TABLE direct_messages (
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    sender_id UUID REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    key_id TEXT NOT NULL,
+    nonce BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL
+);
+
+CREATE INDEX direct_messages_conversation_id_idx ON direct_messages (conversation_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE direct_messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
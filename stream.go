@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/chirptext"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/pubsub"
+)
+
+const (
+	streamPublic        = "public"
+	streamAuthor        = "author"
+	streamNotifications = "notifications"
+)
+
+const (
+	streamEventChirp          = "chirp"
+	streamEventNotification   = "notification"
+	streamEventSessionRevoked = "session.revoked"
+)
+
+const (
+	topicChirps = "chirps"
+
+	// streamBuffer is how many events a client may fall behind before it
+	// is disconnected and has to resume with Last-Event-ID.
+	streamBuffer      = 64
+	streamReplayBatch = 500
+	streamHeartbeat   = 15 * time.Second
+	streamWriteLimit  = 10 * time.Second
+	maxStreamAuthors  = 50
+
+	// streamRetention is how far back Last-Event-ID can resume.
+	streamRetention = 24 * time.Hour
+)
+
+func authorTopic(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func notificationTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+func hashtagTopic(tag string) string {
+	return "hashtag:" + strings.ToLower(tag)
+}
+
+// sessionTopic carries the revocations of userID's sessions. Only
+// connections of the user itself listen to it.
+func sessionTopic(userID uuid.UUID) string {
+	return "sessions:" + userID.String()
+}
+
+type sessionRevokedData struct {
+	// SessionID is empty when every session of the user was revoked.
+	SessionID string `json:"session_id"`
+}
+
+// publishSessionRevoked closes the live connections that authenticated
+// with a JWT of sessionID, or all of userID's when sessionID is empty.
+func publishSessionRevoked(ctx context.Context, q *database.Queries, userID uuid.UUID, sessionID string) error {
+	return pubsub.Publish(ctx, q, []string{sessionTopic(userID)}, streamEventSessionRevoked, sessionRevokedData{SessionID: sessionID})
+}
+
+// publishChirp streams a published public chirp to the public stream, its
+// author's stream and the streams of its hashtags. Chirps of shadow-banned
+// authors are not streamed.
+func publishChirp(ctx context.Context, q *database.Queries, chirp database.Chirp, data chirpEventData) error {
+	if chirp.Visibility != visibilityPublic {
+		return nil
+	}
+
+	author, err := q.GetUserById(ctx, chirp.UserID)
+	if err != nil {
+		return err
+	}
+
+	if author.ShadowBanned {
+		return nil
+	}
+
+	topics := []string{topicChirps, authorTopic(chirp.UserID)}
+	for _, tag := range chirptext.Hashtags(chirp.Body) {
+		topics = append(topics, hashtagTopic(tag))
+	}
+
+	return pubsub.Publish(ctx, q, topics, streamEventChirp, data)
+}
+
+func (cfg *apiConfig) runCleanupStreamJob(ctx context.Context, _ json.RawMessage) error {
+	_, err := cfg.db.DeleteStreamEventsBefore(ctx, time.Now().UTC().Add(-streamRetention))
+	return err
+}
+
+// streamTopics works out what the requested stream subscribes to.
+func streamTopics(r *http.Request, viewerID uuid.UUID) ([]string, int, error) {
+	switch stream := r.URL.Query().Get("stream"); stream {
+	case "", streamPublic:
+		return []string{topicChirps}, 0, nil
+	case streamAuthor:
+		raw := r.URL.Query()["author_id"]
+		if len(raw) == 0 || len(raw) > maxStreamAuthors {
+			return nil, 400, fmt.Errorf("give between 1 and %d author_id parameters", maxStreamAuthors)
+		}
+
+		topics := make([]string, 0, len(raw))
+		for _, id := range raw {
+			authorID, err := uuid.Parse(id)
+			if err != nil {
+				return nil, 400, fmt.Errorf("author_id %q is not a UUID", id)
+			}
+			topics = append(topics, authorTopic(authorID))
+		}
+		return topics, 0, nil
+	case streamNotifications:
+		if viewerID == uuid.Nil {
+			return nil, 401, fmt.Errorf("Unauthorized")
+		}
+		return []string{notificationTopic(viewerID)}, 0, nil
+	default:
+		return nil, 400, fmt.Errorf("unknown stream %q", stream)
+	}
+}
+
+// lastEventID reads the Last-Event-ID header that EventSource sends on
+// reconnect, or the last_event_id query parameter for clients that cannot
+// set headers.
+func lastEventID(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("Last-Event-ID must be a positive number")
+	}
+	return id, nil
+}
+
+// visibleChirpEvent reports whether the viewer should see e, leaving out
+// chirps by authors they blocked, muted or are blocked by.
+func visibleChirpEvent(e pubsub.Event, filtered map[uuid.UUID]bool) bool {
+	if e.Type != streamEventChirp || len(filtered) == 0 {
+		return true
+	}
+
+	var data chirpEventData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return false
+	}
+	return !filtered[data.AuthorID]
+}
+
+// HandleStream serves Server-Sent Events. ?stream=public (the default)
+// carries new public chirps, ?stream=author&author_id=... the public chirps
+// of the given authors and ?stream=notifications the caller's
+// notification events. Clients that reconnect with Last-Event-ID get the
+// events they missed first, as far back as streamRetention.
+func (cfg *apiConfig) HandleStream(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewerFromRequest(r)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	topics, code, err := streamTopics(r, viewerID)
+	if err != nil {
+		respondWithError(w, code, err.Error())
+		return
+	}
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	filtered := map[uuid.UUID]bool{}
+	if viewerID != uuid.Nil {
+		authors, err := cfg.db.GetStreamFilteredAuthors(r.Context(), viewerID)
+		if err != nil {
+			respondWithError(w, 500, "Something went wrong")
+			return
+		}
+		for _, id := range authors {
+			filtered[id] = true
+		}
+	}
+
+	// Subscribe before replaying so nothing published in between is lost.
+	// Events the replay already sent are skipped by id.
+	sub := cfg.stream.Subscribe(topics, streamBuffer)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	send := func(e pubsub.Event) bool {
+		if e.ID <= lastID {
+			return true
+		}
+		lastID = e.ID
+
+		if !visibleChirpEvent(e, filtered) {
+			return true
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(streamWriteLimit))
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for replaying := lastID > 0; replaying; {
+		missed, err := cfg.db.GetTopicStreamEventsAfter(r.Context(), database.GetTopicStreamEventsAfterParams{
+			AfterID:   lastID,
+			Topics:    topics,
+			BatchSize: streamReplayBatch,
+		})
+		if err != nil {
+			return
+		}
+
+		for _, e := range missed {
+			if !send(pubsub.NewEvent(e)) {
+				return
+			}
+		}
+		replaying = len(missed) == streamReplayBatch
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteLimit))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			// A closed subscription means the client lagged behind or the
+			// server is shutting down. Either way it reconnects and
+			// resumes from lastID.
+			if !ok || !send(e) {
+				return
+			}
+		}
+	}
+}
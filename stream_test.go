@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/pubsub"
+)
+
+func TestStreamTopics(t *testing.T) {
+	author := uuid.New()
+	viewer := uuid.New()
+
+	cases := []struct {
+		query        string
+		viewerID     uuid.UUID
+		expected     []string
+		expectedCode int
+	}{
+		{query: "", expected: []string{topicChirps}},
+		{query: "stream=author&author_id=" + author.String(), expected: []string{authorTopic(author)}},
+		{query: "stream=author", expectedCode: 400},
+		{query: "stream=author&author_id=nope", expectedCode: 400},
+		{query: "stream=notifications", expectedCode: 401},
+		{query: "stream=notifications", viewerID: viewer, expected: []string{notificationTopic(viewer)}},
+		{query: "stream=home", expectedCode: 400},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/stream?"+c.query, nil)
+			topics, code, err := streamTopics(r, c.viewerID)
+			if code != c.expectedCode || (err != nil) != (c.expectedCode != 0) {
+				t.Fatalf("expected code %v, have got: %v (%v)\n", c.expectedCode, code, err)
+			}
+
+			if fmt.Sprint(topics) != fmt.Sprint(c.expected) {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, topics)
+			}
+		})
+	}
+}
+
+func TestVisibleChirpEvent(t *testing.T) {
+	blocked := uuid.New()
+	data, _ := json.Marshal(chirpEventData{AuthorID: blocked})
+	filtered := map[uuid.UUID]bool{blocked: true}
+
+	cases := []struct {
+		event    pubsub.Event
+		filtered map[uuid.UUID]bool
+		expected bool
+	}{
+		{event: pubsub.Event{Type: streamEventChirp, Data: data}, filtered: nil, expected: true},
+		{event: pubsub.Event{Type: streamEventChirp, Data: data}, filtered: filtered, expected: false},
+		{event: pubsub.Event{Type: streamEventNotification, Data: data}, filtered: filtered, expected: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if got := visibleChirpEvent(c.event, c.filtered); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleStream(t *testing.T) {
+	cfg := &apiConfig{stream: pubsub.NewBroker()}
+	server := httptest.NewServer(http.HandlerFunc(cfg.HandleStream))
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("could not connect: %v\n", err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, have got: %v\n", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for the stream\n")
+			return ""
+		}
+	}
+
+	if line := next(); !strings.HasPrefix(line, "retry: ") {
+		t.Fatalf("expected the retry hint first, have got: %q\n", line)
+	}
+	next()
+
+	cfg.stream.Publish(pubsub.Event{ID: 7, Topics: []string{topicChirps}, Type: streamEventChirp, Data: json.RawMessage(`{"body":"hi"}`)})
+
+	expected := []string{"id: 7", "event: chirp", `data: {"body":"hi"}`, ""}
+	for _, want := range expected {
+		if line := next(); line != want {
+			t.Errorf("expected: %q, have got: %q\n", want, line)
+		}
+	}
+
+	// Closing the broker, as the server does on shutdown, ends the stream.
+	cfg.stream.Close()
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Errorf("expected the stream to end\n")
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("expected the stream to end when the broker closes\n")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/paysis/chirpy/internal/audit"
+	"github.com/paysis/chirpy/internal/database"
+)
+
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+)
+
+// Polka events that change a subscription.
+const (
+	polkaUserUpgraded   = "user.upgraded"
+	polkaUserRenewed    = "user.renewed"
+	polkaPaymentFailed  = "user.payment_failed"
+	polkaUserDowngraded = "user.downgraded"
+	polkaUserRefunded   = "user.refunded"
+)
+
+// subscriptionPeriod is used when an event does not say when the paid
+// period ends.
+const subscriptionPeriod = 30 * 24 * time.Hour
+
+var errNoSubscription = errors.New("user has no subscription")
+
+// nextSubscription works out the subscription after event. current is the
+// zero Subscription for users who never subscribed. periodEnd is the end
+// of the paid period if the event carries one.
+func nextSubscription(current database.Subscription, event string, periodEnd *time.Time, now time.Time, grace time.Duration) (database.UpsertSubscriptionParams, error) {
+	next := database.UpsertSubscriptionParams{
+		UserID:           current.UserID,
+		Status:           current.Status,
+		CurrentPeriodEnd: current.CurrentPeriodEnd,
+		GraceUntil:       current.GraceUntil,
+	}
+	exists := current.Status != ""
+
+	switch event {
+	case polkaUserUpgraded, polkaUserRenewed:
+		end := now.Add(subscriptionPeriod)
+		if event == polkaUserRenewed && exists && current.CurrentPeriodEnd.After(now) {
+			end = current.CurrentPeriodEnd.Add(subscriptionPeriod)
+		}
+		if periodEnd != nil {
+			end = periodEnd.UTC()
+		}
+		next.Status = subscriptionActive
+		next.CurrentPeriodEnd = end
+		next.GraceUntil = end.Add(grace)
+	case polkaPaymentFailed:
+		if !exists {
+			return next, errNoSubscription
+		}
+		if current.Status == subscriptionExpired {
+			return next, nil
+		}
+		next.Status = subscriptionPastDue
+		next.GraceUntil = later(current.CurrentPeriodEnd, now).Add(grace)
+	case polkaUserDowngraded:
+		if !exists {
+			return next, errNoSubscription
+		}
+		if current.Status == subscriptionExpired {
+			return next, nil
+		}
+		next.Status = subscriptionCanceled
+		next.GraceUntil = current.CurrentPeriodEnd
+	case polkaUserRefunded:
+		if !exists {
+			return next, errNoSubscription
+		}
+		next.Status = subscriptionExpired
+		next.CurrentPeriodEnd = now
+		next.GraceUntil = now
+	default:
+		return next, errors.New("not a subscription event")
+	}
+
+	return next, nil
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// applySubscriptionEvent updates the subscription of the user in a Polka
+// event. The users.is_chirpy_red cache follows through a trigger.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, qtx *database.Queries, event polkaEvent) error {
+	userID := event.Data.UserID
+	if _, err := qtx.GetUserById(ctx, userID); errors.Is(err, sql.ErrNoRows) {
+		return errUnknownUser
+	} else if err != nil {
+		return err
+	}
+
+	current, err := qtx.GetSubscriptionForUpdate(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		current = database.Subscription{UserID: userID}
+	} else if err != nil {
+		return err
+	}
+
+	next, err := nextSubscription(current, event.Event, event.Data.PeriodEnd, time.Now().UTC(), cfg.redGracePeriod)
+	if err != nil {
+		return err
+	}
+
+	_, err = qtx.UpsertSubscription(ctx, next)
+	return err
+}
+
+// expireSubscriptions ends subscriptions whose grace period is over.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	userIDs, err := cfg.db.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		err := cfg.audit.Record(ctx, audit.Event{Kind: audit.SubscriptionExpired, TargetID: userID})
+		if err != nil {
+			log.Printf("Could not record %v audit event: %v\n", audit.SubscriptionExpired, err)
+		}
+	}
+
+	if len(userIDs) > 0 {
+		log.Printf("Expired %d subscriptions\n", len(userIDs))
+	}
+	return nil
+}
+
+// subscriptionAuditKind is the audit kind recorded for a processed
+// subscription event.
+func subscriptionAuditKind(event string) audit.Kind {
+	if event == polkaUserUpgraded {
+		return audit.RedUpgraded
+	}
+	return audit.SubscriptionChanged
+}
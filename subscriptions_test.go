@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/paysis/chirpy/internal/database"
+)
+
+func TestNextSubscription(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	grace := 3 * 24 * time.Hour
+	periodEnd := now.Add(10 * 24 * time.Hour)
+	explicitEnd := now.Add(365 * 24 * time.Hour)
+
+	none := database.Subscription{}
+	active := database.Subscription{Status: subscriptionActive, CurrentPeriodEnd: periodEnd, GraceUntil: periodEnd.Add(grace)}
+	lapsed := database.Subscription{Status: subscriptionActive, CurrentPeriodEnd: now.Add(-time.Hour), GraceUntil: now.Add(-time.Hour).Add(grace)}
+	expired := database.Subscription{Status: subscriptionExpired, CurrentPeriodEnd: now.Add(-time.Hour), GraceUntil: now.Add(-time.Hour)}
+
+	cases := []struct {
+		current        database.Subscription
+		event          string
+		periodEnd      *time.Time
+		expectedStatus string
+		expectedEnd    time.Time
+		expectedGrace  time.Time
+		expectedErr    error
+	}{
+		{current: none, event: polkaUserUpgraded, expectedStatus: subscriptionActive, expectedEnd: now.Add(subscriptionPeriod), expectedGrace: now.Add(subscriptionPeriod).Add(grace)},
+		{current: none, event: polkaUserUpgraded, periodEnd: &explicitEnd, expectedStatus: subscriptionActive, expectedEnd: explicitEnd, expectedGrace: explicitEnd.Add(grace)},
+		{current: active, event: polkaUserRenewed, expectedStatus: subscriptionActive, expectedEnd: periodEnd.Add(subscriptionPeriod), expectedGrace: periodEnd.Add(subscriptionPeriod).Add(grace)},
+		{current: lapsed, event: polkaUserRenewed, expectedStatus: subscriptionActive, expectedEnd: now.Add(subscriptionPeriod), expectedGrace: now.Add(subscriptionPeriod).Add(grace)},
+		{current: active, event: polkaPaymentFailed, expectedStatus: subscriptionPastDue, expectedEnd: periodEnd, expectedGrace: periodEnd.Add(grace)},
+		{current: lapsed, event: polkaPaymentFailed, expectedStatus: subscriptionPastDue, expectedEnd: lapsed.CurrentPeriodEnd, expectedGrace: now.Add(grace)},
+		{current: active, event: polkaUserDowngraded, expectedStatus: subscriptionCanceled, expectedEnd: periodEnd, expectedGrace: periodEnd},
+		{current: active, event: polkaUserRefunded, expectedStatus: subscriptionExpired, expectedEnd: now, expectedGrace: now},
+		{current: expired, event: polkaUserDowngraded, expectedStatus: subscriptionExpired, expectedEnd: expired.CurrentPeriodEnd, expectedGrace: expired.GraceUntil},
+		{current: none, event: polkaUserDowngraded, expectedErr: errNoSubscription},
+		{current: none, event: polkaPaymentFailed, expectedErr: errNoSubscription},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			next, err := nextSubscription(c.current, c.event, c.periodEnd, now, grace)
+			if c.expectedErr != nil {
+				if !errors.Is(err, c.expectedErr) {
+					t.Errorf("expected: %v, have got: %v\n", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nextSubscription returned err: %v\n", err)
+			}
+
+			if next.Status != c.expectedStatus || !next.CurrentPeriodEnd.Equal(c.expectedEnd) || !next.GraceUntil.Equal(c.expectedGrace) {
+				t.Errorf("expected %v until %v (grace %v), have got: %v until %v (grace %v)\n",
+					c.expectedStatus, c.expectedEnd, c.expectedGrace, next.Status, next.CurrentPeriodEnd, next.GraceUntil)
+			}
+		})
+	}
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/chirptext"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/delivery"
+)
+
+const (
+	userEventChirpCreated    = "chirp.created"
+	userEventMentionReceived = "mention.received"
+	userEventFollowerAdded   = "follower.added"
+)
+
+var userEventTypes = []string{userEventChirpCreated, userEventMentionReceived, userEventFollowerAdded}
+
+const deliveryStatusPending = "pending"
+
+const (
+	maxWebhookEndpoints = 5
+	deliveryBatch       = 20
+	deliveryTimeout     = 10 * time.Second
+	// deliveryLease must outlast a whole batch of timed out requests.
+	deliveryLease = deliveryBatch * deliveryTimeout * 2
+)
+
+// userEvent is the JSON body posted to webhook endpoints.
+type userEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type chirpEventData struct {
+	ChirpID        uuid.UUID `json:"chirp_id"`
+	AuthorID       uuid.UUID `json:"author_id"`
+	Body           string    `json:"body"`
+	ContentWarning string    `json:"content_warning"`
+	Sensitive      bool      `json:"sensitive"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type followerEventData struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+}
+
+// enqueueUserEvent queues the event for every endpoint of the user that
+// subscribed to it. Callers pass the queries of the transaction that made
+// the change, so the event exists exactly when the change does.
+func enqueueUserEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, eventType string, data any) error {
+	payload, err := json.Marshal(userEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: eventType,
+		Payload:   payload,
+		UserID:    userID,
+	})
+}
+
+// enqueueChirpEvents queues chirp.created for the author and
+// mention.received for the users the chirp mentions, who also get a
+// notification, and streams public chirps. Nothing is sent for chirps
+// that are not published or are hidden, and private chirps notify no one
+// but the author.
+func enqueueChirpEvents(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.Status != chirpStatusPublished || chirp.HiddenAt.Valid {
+		return nil
+	}
+
+	data := chirpEventData{
+		ChirpID:        chirp.ID,
+		AuthorID:       chirp.UserID,
+		Body:           chirp.Body,
+		ContentWarning: chirp.ContentWarning,
+		Sensitive:      chirp.Sensitive,
+		CreatedAt:      chirp.CreatedAt,
+	}
+
+	if err := enqueueUserEvent(ctx, q, chirp.UserID, userEventChirpCreated, data); err != nil {
+		return err
+	}
+
+	if err := publishChirp(ctx, q, chirp, data); err != nil {
+		return err
+	}
+
+	handles := chirptext.Mentions(chirp.Body)
+	if len(handles) == 0 {
+		return nil
+	}
+
+	// Only users who can read the chirp hear about it, so private chirps,
+	// followers-only chirps and those of shadow-banned authors reach no
+	// one they should not.
+	mentioned, err := q.GetMentionedUserIDs(ctx, database.GetMentionedUserIDsParams{
+		ChirpID: chirp.ID,
+		Handles: handles,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range mentioned {
+		if err := enqueueUserEvent(ctx, q, userID, userEventMentionReceived, data); err != nil {
+			return err
+		}
+
+		if err := notifyMention(ctx, q, userID, chirp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliverWebhooks sends the deliveries that are due. A failed delivery is
+// retried with exponential backoff until it runs out of attempts and is
+// marked dead.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) error {
+	due, err := cfg.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseSeconds: deliveryLease.Seconds(),
+		BatchSize:    deliveryBatch,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range due {
+		res := cfg.webhooks.Send(ctx, delivery.Request{
+			ID:        d.ID,
+			URL:       d.Url,
+			Secret:    d.Secret,
+			EventType: d.EventType,
+			Payload:   d.Payload,
+		})
+
+		statusCode := sql.NullInt32{Int32: int32(res.StatusCode), Valid: res.StatusCode != 0}
+		if res.OK() {
+			err = cfg.db.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+				StatusCode: statusCode,
+				ID:         d.ID,
+			})
+		} else {
+			attempts := int(d.Attempts) + 1
+			err = cfg.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+				Dead:          attempts >= delivery.MaxAttempts,
+				StatusCode:    statusCode,
+				LastError:     res.Err.Error(),
+				NextAttemptAt: time.Now().UTC().Add(delivery.Backoff(attempts)),
+				ID:            d.ID,
+			})
+		}
+		if err != nil {
+			log.Printf("Could not record webhook delivery %v: %v\n", d.ID, err)
+		}
+	}
+
+	return nil
+}
+
+type webhookEndpointVal struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointVal(e database.WebhookEndpoint) webhookEndpointVal {
+	return webhookEndpointVal{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		URL:       e.Url,
+		Events:    e.Events,
+	}
+}
+
+type webhookDeliveryVal struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+func newWebhookDeliveryVal(d database.WebhookDelivery) webhookDeliveryVal {
+	val := webhookDeliveryVal{
+		ID:        d.ID,
+		CreatedAt: d.CreatedAt,
+		EventType: d.EventType,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+	}
+
+	if d.Status == deliveryStatusPending {
+		val.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.LastStatusCode.Valid {
+		val.LastStatusCode = &d.LastStatusCode.Int32
+	}
+	if d.DeliveredAt.Valid {
+		val.DeliveredAt = &d.DeliveredAt.Time
+	}
+
+	return val
+}
+
+// validateEventTypes rejects unknown event types and removes duplicates.
+func validateEventTypes(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("events must name at least one of %v", userEventTypes)
+	}
+
+	var unique []string
+	for _, event := range events {
+		if !slices.Contains(userEventTypes, event) {
+			return nil, fmt.Errorf("unknown event %q", event)
+		}
+		if !slices.Contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+
+	return unique, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+func (cfg *apiConfig) HandleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	if err := delivery.ValidateURL(params.URL); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	events, err := validateEventTypes(params.Events)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	count, err := cfg.db.CountWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if count >= maxWebhookEndpoints {
+		respondWithError(w, 400, fmt.Sprintf("You can have at most %d webhook endpoints", maxWebhookEndpoints))
+		return
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    params.URL,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVal := newWebhookEndpointVal(endpoint)
+	retVal.Secret = endpoint.Secret
+
+	respondWithJSON(w, 201, retVal)
+}
+
+func (cfg *apiConfig) HandleListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	endpoints, err := cfg.db.GetWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]webhookEndpointVal, 0, len(endpoints))
+	for _, e := range endpoints {
+		retVals = append(retVals, newWebhookEndpointVal(e))
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+func (cfg *apiConfig) HandleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the endpoint ID is of type UUID")
+		return
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if deleted == 0 {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// ownedEndpoint loads the endpoint named in the path if it belongs to the
+// caller.
+func (cfg *apiConfig) ownedEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the endpoint ID is of type UUID")
+		return database.WebhookEndpoint{}, false
+	}
+
+	userID, ok := cfg.requireUser(w, r)
+	if !ok {
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return database.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+}
+
+func (cfg *apiConfig) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.ownedEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]webhookDeliveryVal, 0, len(deliveries))
+	for _, d := range deliveries {
+		retVals = append(retVals, newWebhookDeliveryVal(d))
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+// HandleRetryWebhookDelivery moves a dead delivery back to pending so the
+// worker sends it again with a fresh set of attempts.
+func (cfg *apiConfig) HandleRetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	endpoint, ok := cfg.ownedEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the delivery ID is of type UUID")
+		return
+	}
+
+	retried, err := cfg.db.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if retried == 0 {
+		respondWithError(w, 404, "No dead delivery with that ID")
+		return
+	}
+
+	w.WriteHeader(204)
+}
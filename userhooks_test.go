@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestValidateEventTypes(t *testing.T) {
+	cases := []struct {
+		events    []string
+		expected  []string
+		expectErr bool
+	}{
+		{events: []string{userEventChirpCreated}, expected: []string{userEventChirpCreated}},
+		{
+			events:   []string{userEventFollowerAdded, userEventMentionReceived, userEventFollowerAdded},
+			expected: []string{userEventFollowerAdded, userEventMentionReceived},
+		},
+		{events: nil, expectErr: true},
+		{events: []string{userEventChirpCreated, "chirp.deleted"}, expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			events, err := validateEventTypes(c.events)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error=%v, have got: %v\n", c.expectErr, err)
+			}
+
+			if !slices.Equal(events, c.expected) {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, events)
+			}
+		})
+	}
+}
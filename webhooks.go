@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paysis/chirpy/internal/audit"
+	"github.com/paysis/chirpy/internal/database"
+	"github.com/paysis/chirpy/internal/jobs"
+)
+
+const webhookProviderPolka = "polka"
+
+const (
+	webhookStatusPending   = "pending"
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+const (
+	maxWebhookAttempts  = 5
+	webhookProcessLimit = 30 * time.Second
+)
+
+// errUnknownUser fails an event for good, as retrying will not make the
+// user appear.
+var errUnknownUser = errors.New("unknown user")
+
+var polkaSubscriptionEvents = map[string]bool{
+	polkaUserUpgraded:   true,
+	polkaUserRenewed:    true,
+	polkaPaymentFailed:  true,
+	polkaUserDowngraded: true,
+	polkaUserRefunded:   true,
+}
+
+// polkaEvent is the body of a Polka webhook. Polka sends an id with each
+// event. Deliveries without one are keyed by a hash of the body instead,
+// so byte-identical redeliveries are still caught.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID    uuid.UUID  `json:"user_id"`
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+func polkaEventID(event polkaEvent, body []byte) string {
+	if event.ID != "" {
+		return event.ID
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// HandlePolkaWebhook stores the delivery and acknowledges it right away.
+// The event is processed by a job enqueued in the same transaction, which
+// retries it until it runs out of attempts.
+func (cfg *apiConfig) HandlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	if !cfg.polka.verify(r, body, time.Now()) {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	params := polkaEvent{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		respondWithError(w, 400, "Bad request")
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	event, err := qtx.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:  webhookProviderPolka,
+		EventID:   polkaEventID(params, body),
+		EventType: params.Event,
+		Payload:   body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(204) // seen before
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	_, err = jobs.Enqueue(r.Context(), qtx, jobs.NewJob{
+		Type:        jobProcessWebhookEvent,
+		Payload:     webhookEventJob{EventID: event.ID},
+		MaxAttempts: maxWebhookAttempts,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+type webhookEventJob struct {
+	EventID uuid.UUID `json:"event_id"`
+}
+
+func (cfg *apiConfig) runProcessWebhookEventJob(ctx context.Context, payload json.RawMessage) error {
+	var job webhookEventJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookProcessLimit)
+	defer cancel()
+
+	err := cfg.processWebhookEvent(ctx, job.EventID)
+	if errors.Is(err, errUnknownUser) {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// processWebhookEvent applies a stored event and records the outcome. It
+// does nothing for events that are already done, so it is safe to call
+// more than once.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, id uuid.UUID) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	event, err := qtx.LockWebhookEvent(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	params := polkaEvent{}
+	status := webhookStatusIgnored
+	err = json.Unmarshal(event.Payload, &params)
+	if err == nil && polkaSubscriptionEvents[params.Event] {
+		status, err = subscriptionEventStatus(cfg.applySubscriptionEvent(ctx, qtx, params))
+	}
+
+	if err != nil {
+		tx.Rollback()
+		if failErr := cfg.db.FailWebhookEvent(ctx, database.FailWebhookEventParams{
+			LastError: err.Error(),
+			ID:        id,
+		}); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+
+	if err := qtx.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		Status: status,
+		ID:     id,
+	}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if status == webhookStatusProcessed {
+		kind := subscriptionAuditKind(params.Event)
+		err := cfg.audit.Record(ctx, audit.Event{
+			Kind:     kind,
+			TargetID: params.Data.UserID,
+			Metadata: map[string]string{
+				"source":   webhookProviderPolka,
+				"event":    params.Event,
+				"event_id": event.EventID,
+			},
+		})
+		if err != nil {
+			log.Printf("Could not record %v audit event: %v\n", kind, err)
+		}
+	}
+
+	return nil
+}
+
+// subscriptionEventStatus is the status a subscription event is finished
+// with after applying it returned err. Events that suspend or end a
+// subscription the user never had change nothing, and retrying them would
+// not either, so they are ignored instead of failed.
+func subscriptionEventStatus(err error) (string, error) {
+	switch {
+	case errors.Is(err, errNoSubscription):
+		return webhookStatusIgnored, nil
+	case err != nil:
+		return webhookStatusFailed, err
+	default:
+		return webhookStatusProcessed, nil
+	}
+}
+
+type webhookEventVal struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func newWebhookEventVal(e database.WebhookEvent) webhookEventVal {
+	var processedAt *time.Time
+	if e.ProcessedAt.Valid {
+		processedAt = &e.ProcessedAt.Time
+	}
+
+	return webhookEventVal{
+		ID:          e.ID,
+		Provider:    e.Provider,
+		EventID:     e.EventID,
+		EventType:   e.EventType,
+		Payload:     e.Payload,
+		Status:      e.Status,
+		Attempts:    e.Attempts,
+		LastError:   e.LastError,
+		ReceivedAt:  e.ReceivedAt,
+		ProcessedAt: processedAt,
+	}
+}
+
+func (cfg *apiConfig) HandleListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	statuses := []string{webhookStatusPending, webhookStatusProcessed, webhookStatusIgnored, webhookStatusFailed}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case webhookStatusPending, webhookStatusProcessed, webhookStatusIgnored, webhookStatusFailed:
+		statuses = []string{status}
+	default:
+		respondWithError(w, 400, fmt.Sprintf("unknown status %q", status))
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	events, err := cfg.db.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Statuses:   statuses,
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	retVals := make([]webhookEventVal, 0, len(events))
+	for _, e := range events {
+		retVals = append(retVals, newWebhookEventVal(e))
+	}
+
+	respondWithJSON(w, 200, retVals)
+}
+
+func (cfg *apiConfig) HandleGetWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the event ID is of type UUID")
+		return
+	}
+
+	event, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	respondWithJSON(w, 200, newWebhookEventVal(event))
+}
+
+// HandleReplayWebhookEvent processes a stored event again, whatever its
+// status, and returns the event with the outcome.
+func (cfg *apiConfig) HandleReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, 400, "Please make sure the event ID is of type UUID")
+		return
+	}
+
+	if _, err := cfg.db.RequeueWebhookEvent(r.Context(), eventID); err != nil {
+		respondWithError(w, 404, "Not found")
+		return
+	}
+
+	if err := cfg.processWebhookEvent(r.Context(), eventID); err != nil {
+		log.Printf("Replay of webhook event %v failed: %v\n", eventID, err)
+	}
+
+	event, err := cfg.db.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	respondWithJSON(w, 200, newWebhookEventVal(event))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestPolkaEventID(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+
+	cases := []struct {
+		event    polkaEvent
+		body     []byte
+		expected string
+	}{
+		{event: polkaEvent{ID: "evt_123"}, body: body, expected: "evt_123"},
+		{event: polkaEvent{}, body: body, expected: "sha256:b26304ff4b3e7ab8f562aa84d69404f91ae6d735d4cda83f3740b7d76e1cd660"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if got := polkaEventID(c.event, c.body); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestSubscriptionEventStatus(t *testing.T) {
+	failure := errors.New("connection reset")
+
+	cases := []struct {
+		err            error
+		expectedStatus string
+		expectedErr    error
+	}{
+		{err: nil, expectedStatus: webhookStatusProcessed},
+		// A downgrade, refund or failed payment for a user who never
+		// subscribed cannot succeed on a retry.
+		{err: errNoSubscription, expectedStatus: webhookStatusIgnored},
+		{err: fmt.Errorf("downgrade: %w", errNoSubscription), expectedStatus: webhookStatusIgnored},
+		{err: errUnknownUser, expectedStatus: webhookStatusFailed, expectedErr: errUnknownUser},
+		{err: failure, expectedStatus: webhookStatusFailed, expectedErr: failure},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			status, err := subscriptionEventStatus(c.err)
+
+			if status != c.expectedStatus {
+				t.Errorf("expected status: %v, have got: %v\n", c.expectedStatus, status)
+			}
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("expected error: %v, have got: %v\n", c.expectedErr, err)
+			}
+		})
+	}
+}
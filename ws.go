@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/paysis/chirpy/internal/auth"
+	"github.com/paysis/chirpy/internal/chirptext"
+	"github.com/paysis/chirpy/internal/pubsub"
+)
+
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsPing        = "ping"
+)
+
+const (
+	wsSubscribed   = "subscribed"
+	wsUnsubscribed = "unsubscribed"
+	wsEvent        = "event"
+	wsError        = "error"
+	wsPong         = "pong"
+)
+
+// Close codes in the range reserved for applications. Clients reconnect
+// after 4001 with a fresh JWT and after 4003 right away; 4002 means they
+// have to log in again.
+const (
+	wsCloseTokenExpired   = 4001
+	wsCloseSessionRevoked = 4002
+	wsCloseTooSlow        = 4003
+)
+
+const (
+	wsChannelNotifications = "notifications"
+	wsChannelUser          = "user:"
+	wsChannelHashtag       = "hashtag:"
+)
+
+const (
+	// wsBuffer is how many events a client may fall behind before it is
+	// disconnected.
+	wsBuffer      = 64
+	wsPingPeriod  = 30 * time.Second
+	wsPongWait    = 60 * time.Second
+	wsWriteLimit  = 10 * time.Second
+	wsMaxMessage  = 4096
+	wsMaxChannels = 100
+)
+
+// CheckOrigin is left unset on purpose. The default lets through requests
+// without an Origin header, as native clients send them, and turns away
+// browsers on pages served from another host. Browsers cannot attach a
+// bearer token to a WebSocket handshake anyway, so this mostly matters if
+// the endpoint ever accepts cookies; a web client on another origin needs
+// an explicit allow list here.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type wsClientMessage struct {
+	Type string `json:"type"`
+	// ID is echoed in the reply so clients can match it to the request.
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+}
+
+type wsServerMessage struct {
+	Type     string          `json:"type"`
+	ID       string          `json:"id,omitempty"`
+	Channel  string          `json:"channel,omitempty"`
+	Channels []string        `json:"channels,omitempty"`
+	Event    string          `json:"event,omitempty"`
+	EventID  int64           `json:"event_id,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// wsChannelTopic parses a channel name into its canonical form and the
+// topic it listens to. "notifications" is always the caller's own.
+func wsChannelTopic(channel string, userID uuid.UUID) (string, string, error) {
+	switch {
+	case channel == wsChannelNotifications:
+		return channel, notificationTopic(userID), nil
+	case strings.HasPrefix(channel, wsChannelUser):
+		authorID, err := uuid.Parse(strings.TrimPrefix(channel, wsChannelUser))
+		if err != nil {
+			return "", "", fmt.Errorf("%q does not name a user ID", channel)
+		}
+		return wsChannelUser + authorID.String(), authorTopic(authorID), nil
+	case strings.HasPrefix(channel, wsChannelHashtag):
+		tag := strings.TrimPrefix(channel, wsChannelHashtag)
+		if tags := chirptext.Hashtags("#" + tag); len(tags) != 1 || tags[0] != strings.ToLower(tag) {
+			return "", "", fmt.Errorf("%q does not name a hashtag", channel)
+		}
+		return wsChannelHashtag + strings.ToLower(tag), hashtagTopic(tag), nil
+	default:
+		return "", "", fmt.Errorf("unknown channel %q", channel)
+	}
+}
+
+// wsSession is the state of one WebSocket connection. It is only used by
+// the goroutine writing to the connection.
+type wsSession struct {
+	userID    uuid.UUID
+	sessionID string
+	filtered  map[uuid.UUID]bool
+	sub       *pubsub.Subscription
+	// channels maps the subscribed channels to their topics.
+	channels map[string]string
+}
+
+func (s *wsSession) handle(msg wsClientMessage) wsServerMessage {
+	reply := wsServerMessage{ID: msg.ID}
+
+	switch msg.Type {
+	case wsPing:
+		reply.Type = wsPong
+		return reply
+	case wsSubscribe, wsUnsubscribe:
+	default:
+		reply.Type = wsError
+		reply.Error = fmt.Sprintf("unknown message type %q", msg.Type)
+		return reply
+	}
+
+	channel, topic, err := wsChannelTopic(msg.Channel, s.userID)
+	if err != nil {
+		reply.Type = wsError
+		reply.Error = err.Error()
+		return reply
+	}
+	reply.Channel = channel
+
+	if msg.Type == wsUnsubscribe {
+		if _, ok := s.channels[channel]; ok {
+			delete(s.channels, channel)
+			s.sub.Remove(topic)
+		}
+		reply.Type = wsUnsubscribed
+		return reply
+	}
+
+	if _, ok := s.channels[channel]; !ok && len(s.channels) >= wsMaxChannels {
+		reply.Type = wsError
+		reply.Error = fmt.Sprintf("you can subscribe to at most %d channels", wsMaxChannels)
+		return reply
+	}
+
+	s.channels[channel] = topic
+	s.sub.Add(topic)
+	reply.Type = wsSubscribed
+	return reply
+}
+
+// revokes reports whether e revokes the session of the connection.
+func (s *wsSession) revokes(e pubsub.Event) bool {
+	if e.Type != streamEventSessionRevoked || !slices.Contains(e.Topics, sessionTopic(s.userID)) {
+		return false
+	}
+
+	var data sessionRevokedData
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return false
+	}
+	return data.SessionID == "" || data.SessionID == s.sessionID
+}
+
+// event turns e into a message for the channels it was published to. It
+// reports false when the client should not get it.
+func (s *wsSession) event(e pubsub.Event) (wsServerMessage, bool) {
+	var channels []string
+	for channel, topic := range s.channels {
+		if slices.Contains(e.Topics, topic) {
+			channels = append(channels, channel)
+		}
+	}
+
+	// Events buffered before an unsubscribe may no longer match.
+	if len(channels) == 0 || !visibleChirpEvent(e, s.filtered) {
+		return wsServerMessage{}, false
+	}
+	slices.Sort(channels)
+
+	return wsServerMessage{
+		Type:     wsEvent,
+		Channels: channels,
+		Event:    e.Type,
+		EventID:  e.ID,
+		Data:     e.Data,
+	}, true
+}
+
+// HandleWebSocket upgrades to a WebSocket that authenticates with the JWT
+// in the Authorization header. Clients send
+//
+//	{"type": "subscribe", "id": "1", "channel": "hashtag:golang"}
+//
+// and the same with "unsubscribe" for the channels "user:<id>",
+// "hashtag:<tag>" and "notifications". The connection is closed when the
+// JWT expires or its refresh token is revoked.
+func (cfg *apiConfig) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	// Subscribe before the user is checked, so that a session revoked or
+	// a user suspended after the check still closes the connection.
+	sub := cfg.stream.Subscribe([]string{sessionTopic(claims.UserID)}, wsBuffer)
+
+	user, err := cfg.authenticate(r)
+	if errors.Is(err, errSuspended) {
+		sub.Close()
+		respondWithError(w, 403, "Account is suspended")
+		return
+	}
+	if err != nil {
+		sub.Close()
+		respondWithError(w, 401, "Unauthorized")
+		return
+	}
+
+	authors, err := cfg.db.GetStreamFilteredAuthors(r.Context(), user.ID)
+	if err != nil {
+		sub.Close()
+		respondWithError(w, 500, "Something went wrong")
+		return
+	}
+
+	filtered := map[uuid.UUID]bool{}
+	for _, id := range authors {
+		filtered[id] = true
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already responded.
+		sub.Close()
+		return
+	}
+
+	serveWebSocket(conn, &wsSession{
+		userID:    user.ID,
+		sessionID: claims.SessionID,
+		filtered:  filtered,
+		sub:       sub,
+		channels:  map[string]string{},
+	}, claims.ExpiresAt)
+}
+
+// serveWebSocket runs the connection until either side ends it. Reads
+// happen on their own goroutine and everything else, writes included, on
+// this one.
+func serveWebSocket(conn *websocket.Conn, s *wsSession, expiresAt time.Time) {
+	done := make(chan struct{})
+	defer conn.Close()
+	defer close(done)
+	defer s.sub.Close()
+
+	incoming := make(chan wsClientMessage)
+	readErr := make(chan error, 1)
+
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+			var msg wsClientMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				msg = wsClientMessage{Type: "invalid JSON"}
+			}
+
+			select {
+			case incoming <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	send := func(msg wsServerMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteLimit))
+		return conn.WriteJSON(msg) == nil
+	}
+
+	closeWith := func(code int, reason string) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteLimit))
+	}
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-readErr:
+			return
+		case msg := <-incoming:
+			if !send(s.handle(msg)) {
+				return
+			}
+		case e, ok := <-s.sub.Events():
+			if !ok {
+				if s.sub.Lagged() {
+					closeWith(wsCloseTooSlow, "too slow")
+				} else {
+					closeWith(websocket.CloseGoingAway, "server is shutting down")
+				}
+				return
+			}
+
+			if s.revokes(e) {
+				closeWith(wsCloseSessionRevoked, "session revoked")
+				return
+			}
+
+			if msg, ok := s.event(e); ok && !send(msg) {
+				return
+			}
+		case <-ping.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteLimit)) != nil {
+				return
+			}
+		case <-expiry.C:
+			closeWith(wsCloseTokenExpired, "token expired")
+			return
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/paysis/chirpy/internal/pubsub"
+)
+
+func TestWSChannelTopic(t *testing.T) {
+	viewer := uuid.New()
+	author := uuid.New()
+
+	cases := []struct {
+		channel         string
+		expectedChannel string
+		expectedTopic   string
+		expectErr       bool
+	}{
+		{channel: "notifications", expectedChannel: "notifications", expectedTopic: notificationTopic(viewer)},
+		{channel: "user:" + strings.ToUpper(author.String()), expectedChannel: "user:" + author.String(), expectedTopic: authorTopic(author)},
+		{channel: "hashtag:GoLang", expectedChannel: "hashtag:golang", expectedTopic: "hashtag:golang"},
+		{channel: "user:nope", expectErr: true},
+		{channel: "hashtag:go lang", expectErr: true},
+		{channel: "hashtag:123", expectErr: true},
+		{channel: "notifications:" + author.String(), expectErr: true},
+		{channel: "chirps", expectErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			channel, topic, err := wsChannelTopic(c.channel, viewer)
+			if (err != nil) != c.expectErr {
+				t.Fatalf("expected error: %v, have got: %v\n", c.expectErr, err)
+			}
+
+			if channel != c.expectedChannel || topic != c.expectedTopic {
+				t.Errorf("expected: %v %v, have got: %v %v\n", c.expectedChannel, c.expectedTopic, channel, topic)
+			}
+		})
+	}
+}
+
+func TestWSSessionHandle(t *testing.T) {
+	b := pubsub.NewBroker()
+	s := &wsSession{
+		userID:   uuid.New(),
+		sub:      b.Subscribe(nil, 10),
+		channels: map[string]string{},
+	}
+
+	cases := []struct {
+		msg          wsClientMessage
+		expectedType string
+		channels     int
+	}{
+		{msg: wsClientMessage{Type: "subscribe", Channel: "hashtag:go"}, expectedType: wsSubscribed, channels: 1},
+		{msg: wsClientMessage{Type: "subscribe", Channel: "hashtag:Go"}, expectedType: wsSubscribed, channels: 1},
+		{msg: wsClientMessage{Type: "subscribe", Channel: "notifications"}, expectedType: wsSubscribed, channels: 2},
+		{msg: wsClientMessage{Type: "unsubscribe", Channel: "hashtag:go"}, expectedType: wsUnsubscribed, channels: 1},
+		{msg: wsClientMessage{Type: "subscribe", Channel: "everything"}, expectedType: wsError, channels: 1},
+		{msg: wsClientMessage{Type: "publish", Channel: "hashtag:go"}, expectedType: wsError, channels: 1},
+		{msg: wsClientMessage{Type: "ping", ID: "7"}, expectedType: wsPong, channels: 1},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			reply := s.handle(c.msg)
+			if reply.Type != c.expectedType || reply.ID != c.msg.ID {
+				t.Errorf("expected: %v, have got: %+v\n", c.expectedType, reply)
+			}
+
+			if len(s.channels) != c.channels {
+				t.Errorf("expected %d channels, have got: %v\n", c.channels, s.channels)
+			}
+		})
+	}
+}
+
+func TestWSSessionRevokes(t *testing.T) {
+	userID := uuid.New()
+	s := &wsSession{userID: userID, sessionID: "abc"}
+
+	revoked := func(sessionID string) pubsub.Event {
+		data, _ := json.Marshal(sessionRevokedData{SessionID: sessionID})
+		return pubsub.Event{Topics: []string{sessionTopic(userID)}, Type: streamEventSessionRevoked, Data: data}
+	}
+
+	cases := []struct {
+		event    pubsub.Event
+		expected bool
+	}{
+		{event: revoked("abc"), expected: true},
+		{event: revoked(""), expected: true},
+		{event: revoked("other"), expected: false},
+		{event: pubsub.Event{Topics: []string{sessionTopic(uuid.New())}, Type: streamEventSessionRevoked, Data: []byte(`{}`)}, expected: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			if got := s.revokes(c.event); got != c.expected {
+				t.Errorf("expected: %v, have got: %v\n", c.expected, got)
+			}
+		})
+	}
+}
+
+// wsTestServer serves a WebSocket for userID on b that expires after
+// lifetime, without authenticating.
+func wsTestServer(t *testing.T, b *pubsub.Broker, userID uuid.UUID, lifetime time.Duration) *websocket.Conn {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		serveWebSocket(conn, &wsSession{
+			userID:    userID,
+			sessionID: "abc",
+			sub:       b.Subscribe([]string{sessionTopic(userID)}, wsBuffer),
+			channels:  map[string]string{},
+		}, time.Now().Add(lifetime))
+	}))
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("could not dial: %v\n", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	return conn
+}
+
+func expectClose(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != code {
+			t.Errorf("expected close code %d, have got: %v\n", code, err)
+		}
+		return
+	}
+}
+
+func TestServeWebSocket(t *testing.T) {
+	b := pubsub.NewBroker()
+	userID := uuid.New()
+	conn := wsTestServer(t, b, userID, time.Minute)
+
+	conn.WriteJSON(wsClientMessage{Type: "subscribe", ID: "1", Channel: "hashtag:go"})
+	var reply wsServerMessage
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != wsSubscribed || reply.ID != "1" {
+		t.Fatalf("expected a subscribed reply, have got: %+v (%v)\n", reply, err)
+	}
+
+	b.Publish(pubsub.Event{ID: 1, Topics: []string{hashtagTopic("rust")}, Type: streamEventChirp, Data: []byte(`{}`)})
+	b.Publish(pubsub.Event{ID: 2, Topics: []string{topicChirps, hashtagTopic("go")}, Type: streamEventChirp, Data: []byte(`{}`)})
+
+	var event wsServerMessage
+	if err := conn.ReadJSON(&event); err != nil || event.Type != wsEvent || event.EventID != 2 {
+		t.Fatalf("expected event 2, have got: %+v (%v)\n", event, err)
+	}
+
+	if fmt.Sprint(event.Channels) != "[hashtag:go]" {
+		t.Errorf("expected: [hashtag:go], have got: %v\n", event.Channels)
+	}
+
+	data, _ := json.Marshal(sessionRevokedData{SessionID: "abc"})
+	b.Publish(pubsub.Event{ID: 3, Topics: []string{sessionTopic(userID)}, Type: streamEventSessionRevoked, Data: data})
+	expectClose(t, conn, wsCloseSessionRevoked)
+}
+
+func TestServeWebSocketCloses(t *testing.T) {
+	cases := []struct {
+		lifetime time.Duration
+		flood    bool
+		expected int
+	}{
+		{lifetime: 50 * time.Millisecond, expected: wsCloseTokenExpired},
+		{lifetime: time.Minute, flood: true, expected: wsCloseTooSlow},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Case %d", i+1), func(t *testing.T) {
+			b := pubsub.NewBroker()
+			userID := uuid.New()
+			conn := wsTestServer(t, b, userID, c.lifetime)
+
+			if c.flood {
+				// Publish never waits for the connection, so a burst
+				// overflows the buffer.
+				conn.WriteJSON(wsClientMessage{Type: "ping"})
+				conn.ReadJSON(&wsServerMessage{})
+				for id := int64(1); id <= wsBuffer*10; id++ {
+					b.Publish(pubsub.Event{ID: id, Topics: []string{sessionTopic(userID)}, Type: streamEventChirp})
+				}
+			}
+
+			expectClose(t, conn, c.expected)
+		})
+	}
+}